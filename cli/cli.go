@@ -1,6 +1,7 @@
 package cli
 
 import (
+	"crypto/tls"
 	"fmt"
 	"strings"
 	"time"
@@ -10,21 +11,48 @@ import (
 	"github.com/enenisme/definger/finger"
 	"github.com/enenisme/definger/logger"
 	"github.com/enenisme/definger/pkg"
+	"github.com/enenisme/definger/rules"
 	"github.com/enenisme/definger/utils"
 )
 
 // Args 定义命令行参数结构
 type Args struct {
-	URL        string // 目标URL
-	RuleFile   string // 规则文件路径
-	TargetFile string // 目标文件路径
-	LogLevel   int    // 日志级别
-	Timeout    int    // 超时时间
-	OutputFile string // 输出文件路径(excel)
+	URL        string   // 目标URL
+	RuleFiles  []string // 规则文件或目录路径
+	TargetFile string   // 目标文件路径
+	LogLevel   int      // 日志级别
+	Timeout    int      // 超时时间
+	OutputFile string   // 输出文件路径(.xlsx/.json)
+	JARM       bool     // 是否进行JARM指纹探测
+
+	TLSCiphers    string // TLS加密套件列表
+	TLSMinVersion string // 最低TLS版本
+	TLSMaxVersion string // 最高TLS版本
+	SNI           string // 覆盖的SNI主机名
+	NoSNI         bool   // 是否禁用SNI
+	ClientCert    string // 客户端证书文件路径
+	ClientKey     string // 客户端私钥文件路径
+	NoTLSFallback bool   // 是否禁用TLS回退
+
+	Proxy     string // 代理地址
+	ProxyFile string // 代理列表文件路径
+
+	Threads         int     // 批量识别并发目标数
+	RateLimit       float64 // 全局每秒请求数
+	HostConcurrency int     // 单主机最大并发请求数
+	HostDelay       int     // 单主机请求间隔(毫秒)
+	MaxBodySize     int     // 响应体读取上限(KB)
+
+	ClientRedirect      bool // 是否跟随客户端跳转
+	ClientRedirectDepth int  // 客户端跳转最大深度
+
+	MinConfidence float64        // 最低置信度
+	RuleFilter    pkg.RuleFilter // 规则筛选条件
 
 	Json2Json   bool   // 是否将旧版指纹(JSON)文件转换为新版指纹(JSON)文件
 	OldJsonFile string // JSON文件
 	NewJsonFile string // JSON文件
+	MappingFile string // 元数据映射文件
 }
 
 // NewArgs 创建新的Args对象
@@ -36,15 +64,46 @@ type Args struct {
 func NewArgs(c *cli.Context) *Args {
 	return &Args{
 		URL:        c.String("url"),
-		RuleFile:   c.String("ruleFile"),
+		RuleFiles:  c.StringSlice("ruleFile"),
 		TargetFile: c.String("targetFile"),
 		LogLevel:   c.Int("logLevel"),
 		Timeout:    c.Int("timeout"),
 		OutputFile: c.String("outputFile"),
+		JARM:       c.Bool("jarm"),
+
+		TLSCiphers:    c.String("tlsCiphers"),
+		TLSMinVersion: c.String("tlsMinVersion"),
+		TLSMaxVersion: c.String("tlsMaxVersion"),
+		SNI:           c.String("sni"),
+		NoSNI:         c.Bool("noSni"),
+		ClientCert:    c.String("clientCert"),
+		ClientKey:     c.String("clientKey"),
+		NoTLSFallback: c.Bool("noTlsFallback"),
+
+		Proxy:     c.String("proxy"),
+		ProxyFile: c.String("proxyFile"),
+
+		Threads:         c.Int("threads"),
+		RateLimit:       c.Float64("rateLimit"),
+		HostConcurrency: c.Int("hostConcurrency"),
+		HostDelay:       c.Int("hostDelay"),
+		MaxBodySize:     c.Int("maxBodySize"),
+
+		ClientRedirect:      c.Bool("clientRedirect"),
+		ClientRedirectDepth: c.Int("clientRedirectDepth"),
+
+		MinConfidence: c.Float64("minConfidence"),
+		RuleFilter: pkg.RuleFilter{
+			Tags:        c.StringSlice("tags"),
+			ExcludeTags: c.StringSlice("excludeTags"),
+			Severities:  c.StringSlice("severity"),
+			IDs:         c.StringSlice("ids"),
+		},
 
 		Json2Json:   c.Bool("jsonToJson"),
 		OldJsonFile: c.String("oldJsonFile"),
 		NewJsonFile: c.String("newJsonFile"),
+		MappingFile: c.String("mappingFile"),
 	}
 }
 
@@ -73,7 +132,7 @@ func (a *Args) run() error {
 
 	// 如果需要将旧指纹JSON文件转换为新指纹JSON文件，则执行转换
 	if a.Json2Json {
-		utils.Json2Json(a.OldJsonFile, a.NewJsonFile, logger)
+		utils.Json2Json(a.OldJsonFile, a.NewJsonFile, a.MappingFile, logger)
 	}
 
 	// 如果目标文件不为空，则执行异步指纹识别
@@ -111,15 +170,15 @@ func (a *Args) runAsync(logger *logger.Logger) error {
 //   - error: 错误信息
 func (a *Args) validateArgs(logger *logger.Logger) error {
 	if !a.Json2Json {
-		if a.RuleFile == "" {
-			logger.Warnf("指纹规则文件未指定")
-			return fmt.Errorf("指纹规则文件未指定")
-		}
-
 		if a.TargetFile == "" && a.URL == "" {
 			logger.Warnf("目标文件或URL未指定")
 			return fmt.Errorf("目标文件或URL未指定")
 		}
+
+		if a.MinConfidence < 0 || a.MinConfidence > 1 {
+			logger.Warnf("最低置信度需在0到1之间")
+			return fmt.Errorf("最低置信度需在0到1之间")
+		}
 	} else {
 		if a.OldJsonFile == "" {
 			logger.Warnf("旧版指纹(JSON)文件未指定")
@@ -143,18 +202,133 @@ func (a *Args) validateArgs(logger *logger.Logger) error {
 //   - *pkg.Config: 配置对象
 //   - error: 错误信息
 func (a *Args) loadConfig(logger *logger.Logger) (*pkg.Config, error) {
-	config, err := utils.LoadConfig(a.RuleFile)
+	if len(a.RuleFiles) == 0 {
+		logger.Infof("未指定指纹规则文件, 使用内置规则包(版本 %s)", rules.Version)
+	}
+	config, err := utils.LoadConfig(a.RuleFilter, a.RuleFiles...)
 	if err != nil {
 		logger.Warnf("加载指纹规则文件失败: %v", err)
 		return nil, err
 	}
+	for _, warning := range config.Warnings {
+		logger.Warnf("%s: %s", warning.File, warning)
+	}
 
 	logger.Infof("加载探针服务配置成功！已识别探针数量: %d", len(config.Probes.Probes))
 	logger.Infof("加载指纹服务配置成功！已识别指纹数量: %d", len(config.Tags.Tags))
 
+	// 设置共享HTTP层配置
+	options, err := a.httpOptions()
+	if err != nil {
+		logger.Warnf("HTTP配置无效: %v", err)
+		return nil, err
+	}
+	options.Logger = logger
+	config.Probes.Options = options
+
 	return config, nil
 }
 
+// httpOptions 根据命令行参数生成HTTP层配置
+// 返回:
+//   - *pkg.HTTPOptions: HTTP层配置
+//   - error: 错误信息
+func (a *Args) httpOptions() (*pkg.HTTPOptions, error) {
+	profile := pkg.DefaultTLSProfile()
+	profile.Name = "custom"
+
+	if a.TLSCiphers != "" {
+		suites, err := pkg.ParseCipherSuites(a.TLSCiphers)
+		if err != nil {
+			return nil, err
+		}
+		profile.CipherSuites = suites
+	}
+	if a.TLSMinVersion != "" {
+		version, err := pkg.ParseTLSVersion(a.TLSMinVersion)
+		if err != nil {
+			return nil, err
+		}
+		profile.MinVersion = version
+	}
+	if a.TLSMaxVersion != "" {
+		version, err := pkg.ParseTLSVersion(a.TLSMaxVersion)
+		if err != nil {
+			return nil, err
+		}
+		profile.MaxVersion = version
+	}
+	if profile.MinVersion > profile.MaxVersion {
+		return nil, fmt.Errorf("最低TLS版本不能高于最高TLS版本")
+	}
+
+	profile.ServerName = a.SNI
+	profile.DisableSNI = a.NoSNI
+
+	if a.ClientCert != "" || a.ClientKey != "" {
+		cert, err := tls.LoadX509KeyPair(a.ClientCert, a.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("加载客户端证书失败: %v", err)
+		}
+		profile.Certificates = []tls.Certificate{cert}
+	}
+
+	proxies, err := a.proxies()
+	if err != nil {
+		return nil, err
+	}
+
+	return &pkg.HTTPOptions{
+		TLS:             profile,
+		DisableFallback: a.NoTLSFallback,
+		Proxies:         proxies,
+		RateLimit:       a.RateLimit,
+		HostConcurrency: a.HostConcurrency,
+		HostDelay:       time.Duration(a.HostDelay) * time.Millisecond,
+		MaxBodySize:     int64(a.MaxBodySize) * 1024,
+	}, nil
+}
+
+// proxies 汇总并校验命令行指定的代理
+// 返回:
+//   - []string: 代理地址列表
+//   - error: 错误信息
+func (a *Args) proxies() ([]string, error) {
+	var proxies []string
+	if a.Proxy != "" {
+		proxies = append(proxies, a.Proxy)
+	}
+	if a.ProxyFile != "" {
+		list, err := utils.LoadProxyFile(a.ProxyFile)
+		if err != nil {
+			return nil, fmt.Errorf("加载代理列表文件失败: %v", err)
+		}
+		proxies = append(proxies, list...)
+	}
+
+	for _, proxy := range proxies {
+		if _, err := pkg.ParseProxy(proxy); err != nil {
+			return nil, err
+		}
+	}
+	return proxies, nil
+}
+
+// fingerOptions 根据命令行参数生成指纹识别可选功能配置
+// 返回:
+//   - finger.Options: 可选功能配置
+func (a *Args) fingerOptions() finger.Options {
+	return finger.Options{
+		JARM:    a.JARM,
+		Threads: a.Threads,
+
+		ClientRedirect:      a.ClientRedirect,
+		ClientRedirectDepth: a.ClientRedirectDepth,
+
+		MinConfidence: a.MinConfidence,
+	}
+}
+
 // runFingerprint 执行指纹识别
 // 参数:
 //   - logger: 日志对象
@@ -169,7 +343,8 @@ func (a *Args) runFingerprint(logger *logger.Logger, config *pkg.Config) error {
 		logger.Infof("总执行时间: %s", elapsed)
 	}()
 
-	finger := finger.NewFinger(config.Probes, config.Tags, logger)
+	finger := finger.NewFinger(config.Probes, config.Rules, logger)
+	finger.SetOptions(a.fingerOptions())
 	finger.Run(a.URL)
 	return nil
 }
@@ -181,29 +356,44 @@ func (a *Args) runAsyncFingerprint(logger *logger.Logger, config *pkg.Config, fi
 		logger.Infof("总执行时间: %s", elapsed)
 	}()
 
-	finger := finger.NewFinger(config.Probes, config.Tags, logger)
+	finger := finger.NewFinger(config.Probes, config.Rules, logger)
+	finger.SetOptions(a.fingerOptions())
 	fingers := finger.RunAsync(filePath)
 
-	if a.OutputFile != "" {
-		if strings.HasSuffix(a.OutputFile, ".xlsx") {
-			fingerData := make(map[string]utils.FingerData)
-
-			for _, finger := range fingers {
-				fingerData[finger.Url] = utils.FingerData{
-					Protocol: "TCP/HTTP",
-					Url:      finger.Url,
-					Result:   finger.Result,
-					Title:    finger.Title,
-				}
-			}
-
-			err := utils.SaveExecl(fingerData, a.OutputFile)
-			if err != nil {
-				logger.Warnf("保存Excel文件失败: %v", err)
-				return err
-			}
-			logger.Infof("保存Excel文件成功: %s", a.OutputFile)
+	if a.OutputFile == "" {
+		return nil
+	}
+
+	fingerData := make(map[string]utils.FingerData)
+	for _, finger := range fingers {
+		fingerData[finger.Url] = utils.FingerData{
+			Protocol:   "TCP/HTTP",
+			Url:        finger.Url,
+			Result:     finger.Result,
+			Confidence: finger.Confidence,
+			Title:      finger.Title,
+			Cert:       finger.Cert.String(),
+			JARM:       finger.JARM,
+			Redirects:  finger.Redirects,
+			FinalURL:   finger.FinalURL,
+		}
+	}
+
+	switch {
+	case strings.HasSuffix(a.OutputFile, ".xlsx"):
+		if err := utils.SaveExecl(fingerData, a.OutputFile); err != nil {
+			logger.Warnf("保存Excel文件失败: %v", err)
+			return err
+		}
+		logger.Infof("保存Excel文件成功: %s", a.OutputFile)
+	case strings.HasSuffix(a.OutputFile, ".json"):
+		if err := utils.SaveJSON(fingerData, a.OutputFile); err != nil {
+			logger.Warnf("保存JSON文件失败: %v", err)
+			return err
 		}
+		logger.Infof("保存JSON文件成功: %s", a.OutputFile)
+	default:
+		logger.Warnf("不支持的输出文件格式: %s, 可选: .xlsx, .json", a.OutputFile)
 	}
 
 	return nil
@@ -0,0 +1,499 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/enenisme/definger/logger"
+	"github.com/enenisme/definger/match"
+	"github.com/enenisme/definger/pkg"
+	"github.com/enenisme/definger/rules"
+	"github.com/enenisme/definger/utils"
+)
+
+// RulesCommand 创建规则管理子命令
+// 返回:
+//   - *cli.Command: rules子命令
+func RulesCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "rules",
+		Usage: "指纹规则管理",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "lint",
+				Usage:     "校验指纹规则文件, 存在错误时以非零状态码退出",
+				ArgsUsage: "[规则文件或目录...]",
+				Flags: []cli.Flag{
+					&cli.StringSliceFlag{
+						Name:    "ruleFile",
+						Aliases: []string{"r"},
+						Usage:   "指定指纹规则文件或目录路径, 可重复指定",
+					},
+					&cli.BoolFlag{
+						Name:    "generic",
+						Aliases: []string{"g"},
+						Usage:   "对过于宽泛、容易误报的关键字给出警告",
+					},
+				},
+				Action: RulesLint,
+			},
+			{
+				Name:      "test",
+				Usage:     "使用规则中的样例响应测试规则, 存在失败样例时以非零状态码退出",
+				ArgsUsage: "[规则文件或目录...]",
+				Flags: []cli.Flag{
+					&cli.StringSliceFlag{
+						Name:    "ruleFile",
+						Aliases: []string{"r"},
+						Usage:   "指定指纹规则文件或目录路径, 可重复指定",
+					},
+				},
+				Action: RulesTest,
+			},
+			{
+				Name:      "import",
+				Usage:     "将其他指纹库转换为指纹规则(JSON)",
+				ArgsUsage: "<源文件>",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "from",
+						Usage:    "指定源格式: " + strings.Join(utils.ImportFormats, ", "),
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:    "output",
+						Aliases: []string{"o"},
+						Usage:   "指定输出文件路径, 未指定时输出到标准输出",
+					},
+					&cli.StringFlag{
+						Name:    "mapping",
+						Aliases: []string{"m"},
+						Usage:   "指定元数据映射文件(JSON/YAML), 按指纹名称填充作者、标签、厂商与产品",
+					},
+				},
+				Action: RulesImport,
+			},
+			{
+				Name:      "export",
+				Usage:     "将指纹规则转换为其他工具的指纹库格式",
+				ArgsUsage: "[规则文件或目录...]",
+				Flags: []cli.Flag{
+					&cli.StringSliceFlag{
+						Name:    "ruleFile",
+						Aliases: []string{"r"},
+						Usage:   "指定指纹规则文件或目录路径, 可重复指定",
+					},
+					&cli.StringFlag{
+						Name:     "to",
+						Usage:    "指定目标格式: " + strings.Join(utils.ExportFormats, ", "),
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:    "output",
+						Aliases: []string{"o"},
+						Usage:   "指定输出路径, nuclei为输出目录, 其他格式为输出文件; 未指定时输出到标准输出",
+					},
+				},
+				Action: RulesExport,
+			},
+			{
+				Name:      "stats",
+				Usage:     "统计历史扫描结果中各指纹的命中情况, 找出从未命中、疑似误报与总是同时命中的指纹",
+				ArgsUsage: "<结果文件(.xlsx/.json)...>",
+				Flags: []cli.Flag{
+					&cli.StringSliceFlag{
+						Name:    "ruleFile",
+						Aliases: []string{"r"},
+						Usage:   "指定指纹规则文件或目录路径, 用于列出从未命中的指纹并按规则标签划分类别",
+					},
+					&cli.Float64Flag{
+						Name:  "maxRatio",
+						Value: 0.5,
+						Usage: "命中比例达到该值的指纹标记为疑似误报",
+					},
+					&cli.IntFlag{
+						Name:  "minCooccur",
+						Value: 2,
+						Usage: "共现统计要求的最少共同命中目标数",
+					},
+					&cli.Float64Flag{
+						Name:  "cooccurRatio",
+						Value: 0.9,
+						Usage: "共同命中目标数占命中较少一方目标数的比例达到该值时视为总是同时命中",
+					},
+					&cli.IntFlag{
+						Name:  "maxProducts",
+						Value: 3,
+						Usage: "同一目标在同一类别中命中的指纹数达到该值时视为冲突, 小于2时不检查",
+					},
+					&cli.BoolFlag{
+						Name:  "json",
+						Usage: "以JSON格式输出统计结果",
+					},
+				},
+				Action: RulesStats,
+			},
+			{
+				Name:  "dump",
+				Usage: "输出内置规则包, 便于在其基础上修改",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "output",
+						Aliases: []string{"o"},
+						Usage:   "指定输出文件路径, 未指定时输出到标准输出",
+					},
+				},
+				Action: RulesDump,
+			},
+		},
+	}
+}
+
+// RulesLint 校验指纹规则文件
+// 参数:
+//   - c: CLI上下文
+//
+// 返回:
+//   - error: 存在错误级别问题时返回退出码为1的错误
+func RulesLint(c *cli.Context) error {
+	log := logger.NewLogger(logger.LogLevelInfo)
+
+	paths, err := ruleFileArgs(c)
+	if err != nil {
+		return cli.Exit(err.Error(), 2)
+	}
+
+	tags, warnings, err := utils.LoadTags(paths...)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("加载指纹规则文件失败: %v", err), 2)
+	}
+
+	issues := append(warnings, pkg.ValidateTags(tags, pkg.ValidateOptions{GenericWords: c.Bool("generic")})...)
+	errCount := 0
+	for _, issue := range issues {
+		if issue.Level == pkg.IssueError {
+			errCount++
+		}
+		fmt.Fprintf(c.App.Writer, "%s: %s\n", issue.File, issue)
+	}
+
+	log.Infof("校验完成: 规则 %d 条, 错误 %d 个, 警告 %d 个", len(tags), errCount, len(issues)-errCount)
+	if errCount > 0 {
+		return cli.Exit("", 1)
+	}
+	return nil
+}
+
+// RulesTest 使用样例响应测试指纹规则
+// 参数:
+//   - c: CLI上下文
+//
+// 返回:
+//   - error: 存在失败样例时返回退出码为1的错误
+func RulesTest(c *cli.Context) error {
+	log := logger.NewLogger(logger.LogLevelInfo)
+
+	paths, err := ruleFileArgs(c)
+	if err != nil {
+		return cli.Exit(err.Error(), 2)
+	}
+
+	tags, _, err := utils.LoadTags(paths...)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("加载指纹规则文件失败: %v", err), 2)
+	}
+
+	quiet := logger.NewLogger(logger.LogLevelError)
+	tested, passed, failed := 0, 0, 0
+	for _, tag := range tags {
+		// 样例文件的相对路径基于规则所在文件的目录
+		results := match.RunSamples(tag, filepath.Dir(tag.Source), quiet)
+		if len(results) == 0 {
+			continue
+		}
+		tested++
+		for _, result := range results {
+			kind := "negative"
+			if result.Positive {
+				kind = "positive"
+			}
+			switch {
+			case result.Err != nil:
+				failed++
+				fmt.Fprintf(c.App.Writer, "FAIL %s %s %s: %v\n", tag.ID, kind, result.Sample, result.Err)
+			case !result.Passed && result.Positive:
+				failed++
+				fmt.Fprintf(c.App.Writer, "FAIL %s %s %s: 期望命中但未命中\n", tag.ID, kind, result.Sample)
+			case !result.Passed:
+				failed++
+				fmt.Fprintf(c.App.Writer, "FAIL %s %s %s: 期望不命中但命中\n", tag.ID, kind, result.Sample)
+			default:
+				passed++
+				fmt.Fprintf(c.App.Writer, "PASS %s %s %s\n", tag.ID, kind, result.Sample)
+			}
+		}
+	}
+
+	log.Infof("测试完成: 规则 %d 条(含样例 %d 条), 通过 %d 个, 失败 %d 个", len(tags), tested, passed, failed)
+	if failed > 0 {
+		return cli.Exit("", 1)
+	}
+	return nil
+}
+
+// RulesImport 将其他指纹库转换为指纹规则
+// 参数:
+//   - c: CLI上下文
+//
+// 返回:
+//   - error: 转换失败时返回退出码为2的错误
+func RulesImport(c *cli.Context) error {
+	log := logger.NewLogger(logger.LogLevelInfo)
+
+	if c.Args().Len() != 1 {
+		return cli.Exit("请指定一个源文件", 2)
+	}
+	source := c.Args().First()
+	data, err := os.ReadFile(source)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("读取源文件失败: %v", err), 2)
+	}
+
+	mapping, err := utils.LoadMetaMapping(c.String("mapping"))
+	if err != nil {
+		return cli.Exit(err.Error(), 2)
+	}
+
+	tags, warnings, err := utils.ImportRules(c.String("from"), data, mapping)
+	if err != nil {
+		return cli.Exit(err.Error(), 2)
+	}
+	// 警告输出到标准错误, 避免混入标准输出的规则
+	for _, warning := range warnings {
+		fmt.Fprintf(c.App.ErrWriter, "%s: %s\n", source, warning)
+	}
+
+	out, err := json.MarshalIndent(tags, "", "  ")
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("序列化指纹规则失败: %v", err), 2)
+	}
+	if output := c.String("output"); output != "" {
+		if err := os.WriteFile(output, out, 0644); err != nil {
+			return cli.Exit(fmt.Sprintf("写入输出文件失败: %v", err), 2)
+		}
+	} else {
+		fmt.Fprintln(c.App.Writer, string(out))
+	}
+
+	log.Infof("导入完成: 规则 %d 条, 警告 %d 个", len(tags), len(warnings))
+	return nil
+}
+
+// RulesExport 将指纹规则转换为其他工具的指纹库格式
+// 参数:
+//   - c: CLI上下文
+//
+// 返回:
+//   - error: 转换失败时返回退出码为2的错误
+func RulesExport(c *cli.Context) error {
+	log := logger.NewLogger(logger.LogLevelInfo)
+
+	paths, err := ruleFileArgs(c)
+	if err != nil {
+		return cli.Exit(err.Error(), 2)
+	}
+
+	tags, _, err := utils.LoadTags(paths...)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("加载指纹规则文件失败: %v", err), 2)
+	}
+
+	files, warnings, err := utils.ExportRules(c.String("to"), tags)
+	if err != nil {
+		return cli.Exit(err.Error(), 2)
+	}
+	// 警告输出到标准错误, 避免混入标准输出的指纹库
+	for _, warning := range warnings {
+		fmt.Fprintf(c.App.ErrWriter, "%s: %s\n", warning.File, warning)
+	}
+
+	if err := writeExportedFiles(c, files); err != nil {
+		return cli.Exit(err.Error(), 2)
+	}
+
+	log.Infof("导出完成: 规则 %d 条, 文件 %d 个, 警告 %d 个", len(tags), len(files), len(warnings))
+	return nil
+}
+
+// writeExportedFiles 写出导出的文件
+// 未指定输出路径时输出到标准输出(多个文件以YAML文档分隔符分隔); nuclei或多个文件时输出路径为目录
+// 参数:
+//   - c: CLI上下文
+//   - files: 导出的文件
+//
+// 返回:
+//   - error: 错误信息
+func writeExportedFiles(c *cli.Context, files []utils.ExportedFile) error {
+	output := c.String("output")
+	if output == "" {
+		for i, file := range files {
+			if i > 0 {
+				fmt.Fprintln(c.App.Writer, "---")
+			}
+			fmt.Fprintln(c.App.Writer, strings.TrimRight(string(file.Data), "\n"))
+		}
+		return nil
+	}
+
+	if strings.EqualFold(c.String("to"), "nuclei") || len(files) > 1 {
+		if err := os.MkdirAll(output, 0755); err != nil {
+			return fmt.Errorf("创建输出目录失败: %v", err)
+		}
+		for _, file := range files {
+			if err := os.WriteFile(filepath.Join(output, file.Name), file.Data, 0644); err != nil {
+				return fmt.Errorf("写入输出文件失败: %v", err)
+			}
+		}
+		return nil
+	}
+
+	for _, file := range files {
+		if err := os.WriteFile(output, file.Data, 0644); err != nil {
+			return fmt.Errorf("写入输出文件失败: %v", err)
+		}
+	}
+	return nil
+}
+
+// RulesStats 统计历史扫描结果中各指纹的命中情况
+// 参数:
+//   - c: CLI上下文
+//
+// 返回:
+//   - error: 读取失败时返回退出码为2的错误
+func RulesStats(c *cli.Context) error {
+	if c.Args().Len() == 0 {
+		return cli.Exit("请指定至少一个结果文件", 2)
+	}
+	results, err := utils.LoadResults(c.Args().Slice()...)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("加载结果文件失败: %v", err), 2)
+	}
+
+	var tags []pkg.Tag
+	if paths := c.StringSlice("ruleFile"); len(paths) > 0 {
+		if tags, _, err = utils.LoadTags(paths...); err != nil {
+			return cli.Exit(fmt.Sprintf("加载指纹规则文件失败: %v", err), 2)
+		}
+	}
+
+	stats := utils.RuleStatistics(results, tags, utils.StatsOptions{
+		MinCooccur:   c.Int("minCooccur"),
+		CooccurRatio: c.Float64("cooccurRatio"),
+		MaxProducts:  c.Int("maxProducts"),
+	})
+	if c.Bool("json") {
+		out, err := json.MarshalIndent(stats, "", "  ")
+		if err != nil {
+			return cli.Exit(fmt.Sprintf("序列化统计结果失败: %v", err), 2)
+		}
+		fmt.Fprintln(c.App.Writer, string(out))
+		return nil
+	}
+	printRuleStats(c.App.Writer, stats, c.Float64("maxRatio"))
+	return nil
+}
+
+// RulesDump 输出内置规则包
+// 参数:
+//   - c: CLI上下文
+//
+// 返回:
+//   - error: 写入失败时返回退出码为2的错误
+func RulesDump(c *cli.Context) error {
+	log := logger.NewLogger(logger.LogLevelInfo)
+
+	output := c.String("output")
+	if output == "" {
+		_, err := c.App.Writer.Write(rules.Default)
+		return err
+	}
+	if err := os.WriteFile(output, rules.Default, 0644); err != nil {
+		return cli.Exit(fmt.Sprintf("写入输出文件失败: %v", err), 2)
+	}
+
+	tags, err := utils.LoadDefaultTags()
+	if err != nil {
+		return cli.Exit(err.Error(), 2)
+	}
+	log.Infof("已输出内置规则包(版本 %s): 规则 %d 条", rules.Version, len(tags))
+	return nil
+}
+
+// printRuleStats 以文本形式输出规则命中统计
+// 参数:
+//   - w: 输出目标
+//   - stats: 统计结果
+//   - maxRatio: 疑似误报的命中比例
+func printRuleStats(w io.Writer, stats *utils.RuleStats, maxRatio float64) {
+	fmt.Fprintf(w, "目标 %d 个, 命中指纹 %d 种\n", stats.Targets, len(stats.Rules))
+
+	fmt.Fprintln(w, "\n命中统计:")
+	for _, rule := range stats.Rules {
+		mark := ""
+		if rule.Ratio >= maxRatio {
+			mark = "  [疑似误报]"
+		}
+		fmt.Fprintf(w, "  %6d  %6.1f%%  %s%s\n", rule.Hits, rule.Ratio*100, rule.Name, mark)
+	}
+
+	if len(stats.Unmatched) > 0 {
+		fmt.Fprintf(w, "\n从未命中的指纹(%d):\n", len(stats.Unmatched))
+		for _, name := range stats.Unmatched {
+			fmt.Fprintf(w, "  %s\n", name)
+		}
+	}
+	if len(stats.Unknown) > 0 {
+		fmt.Fprintf(w, "\n规则中不存在的指纹(%d):\n", len(stats.Unknown))
+		for _, name := range stats.Unknown {
+			fmt.Fprintf(w, "  %s\n", name)
+		}
+	}
+	if len(stats.Cooccurrences) > 0 {
+		fmt.Fprintf(w, "\n总是同时命中的指纹(%d):\n", len(stats.Cooccurrences))
+		for _, pair := range stats.Cooccurrences {
+			fmt.Fprintf(w, "  %6d  %6.1f%%  %s + %s\n", pair.Count, pair.Ratio*100, pair.A, pair.B)
+		}
+	}
+	if len(stats.Conflicts) > 0 {
+		fmt.Fprintf(w, "\n命中指纹过多的目标(%d):\n", len(stats.Conflicts))
+		for _, conflict := range stats.Conflicts {
+			category := ""
+			if conflict.Category != "" {
+				category = " [" + conflict.Category + "]"
+			}
+			fmt.Fprintf(w, "  %s%s: %s\n", conflict.URL, category, strings.Join(conflict.Results, ", "))
+		}
+	}
+}
+
+// ruleFileArgs 获取子命令指定的规则文件或目录, 支持 --ruleFile 与位置参数
+// 参数:
+//   - c: CLI上下文
+//
+// 返回:
+//   - []string: 规则文件或目录路径
+//   - error: 错误信息
+func ruleFileArgs(c *cli.Context) ([]string, error) {
+	paths := append(c.StringSlice("ruleFile"), c.Args().Slice()...)
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("指纹规则文件未指定")
+	}
+	return paths, nil
+}
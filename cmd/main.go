@@ -10,5 +10,6 @@ import (
 func main() {
 	app := flag.NewFlag()
 	app.Action = cli.Run
+	app.Commands = append(app.Commands, cli.RulesCommand())
 	app.Run(os.Args)
 }
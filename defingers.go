@@ -3,11 +3,16 @@ package definger
 import (
 	"github.com/enenisme/definger/finger"
 	"github.com/enenisme/definger/logger"
+	"github.com/enenisme/definger/pkg"
 	"github.com/enenisme/definger/utils"
 )
 
 type Definger struct {
 	URL string
+
+	HTTPOptions *pkg.HTTPOptions // 共享HTTP层配置(TLS/代理/限流), 为空时使用默认配置
+	Options     finger.Options   // 指纹识别可选功能
+	RuleFilter  pkg.RuleFilter   // 规则筛选条件, 零值时加载全部规则
 }
 
 func NewDefinger(url string) *Definger {
@@ -18,15 +23,24 @@ func (d *Definger) Definger(path string) ([]string, error) {
 	// 创建日志记录器
 	logger := logger.NewLogger(logger.LogLevel(3))
 
-	config, err := utils.LoadConfig(path)
+	// 未指定规则文件时使用内置规则包
+	var paths []string
+	if path != "" {
+		paths = append(paths, path)
+	}
+	config, err := utils.LoadConfig(d.RuleFilter, paths...)
 	if err != nil {
 		logger.Warnf("加载指纹规则文件失败: %v", err)
+		return nil, err
 	}
 
 	logger.Infof("加载探针服务配置成功！已识别探针数量: %d", len(config.Probes.Probes))
 	logger.Infof("加载指纹服务配置成功！已识别指纹数量: %d", len(config.Tags.Tags))
 
-	finger := finger.NewFinger(config.Probes, config.Tags, logger)
+	config.Probes.Options = d.HTTPOptions
+
+	finger := finger.NewFinger(config.Probes, config.Rules, logger)
+	finger.SetOptions(d.Options)
 	finger.Run(d.URL)
 
 	return finger.Result, nil
@@ -0,0 +1,19 @@
+package definger
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/enenisme/definger/pkg"
+)
+
+func TestDefingerLoadError(t *testing.T) {
+	d := NewDefinger("http://127.0.0.1:1")
+	d.RuleFilter = pkg.RuleFilter{IDs: []string{"no-such-rule"}}
+
+	// 没有符合筛选条件的规则时返回错误, 不能继续使用空配置
+	result, err := d.Definger("")
+	assert.Error(t, err)
+	assert.Nil(t, result)
+}
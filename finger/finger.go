@@ -3,51 +3,88 @@ package finger
 import (
 	"fmt"
 	"log"
+	"net"
+	"net/url"
 	"runtime"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/enenisme/definger/jarm"
 	"github.com/enenisme/definger/logger"
 	"github.com/enenisme/definger/match"
 	"github.com/enenisme/definger/pkg"
 	"github.com/enenisme/definger/utils"
 )
 
+// Options 定义指纹识别的可选功能
+type Options struct {
+	JARM        bool          // 是否对HTTPS目标进行JARM指纹探测
+	JARMTimeout time.Duration // JARM单次探测超时时间
+	Threads     int           // 批量识别时同时处理的目标数, 0表示使用默认值100
+
+	ClientRedirect      bool // 是否跟随meta refresh与JavaScript跳转
+	ClientRedirectDepth int  // 客户端跳转最大深度, 0表示使用默认值3
+
+	MinConfidence float64 // 最低置信度, 低于该值的指纹不计入结果
+}
+
 type Finger struct {
-	Url      string   // 目标URL
-	Result   []string // 指纹结果
-	Title    string   // 标题
-	Protocol string   // 协议
-	favicon  string   // favicon
+	Url        string             // 目标URL
+	Result     []string           // 指纹结果
+	Confidence map[string]float64 // 各指纹的置信度
+	Title      string             // 标题
+	Protocol   string             // 协议
+	Cert       *pkg.CertInfo      // TLS证书摘要
+	JARM       string             // TLS服务JARM指纹
+	Redirects  []string           // 根路径的客户端跳转链(不含起始URL)
+	FinalURL   string             // 根路径经服务端与客户端跳转后的最终URL
+	favicon    string             // favicon
 
 	probes *pkg.Probes    // 探针配置
-	tags   *pkg.Tags      // 指纹标签
+	rules  *pkg.RuleSet   // 编译后的指纹规则
 	logger *logger.Logger // 日志对象
 
-	async bool // 是否异步
+	cache   *pkg.ResponseCache // 当前目标的响应缓存, 标题/favicon/指纹匹配共用
+	async   bool               // 是否异步
+	options Options            // 可选功能配置
+	jarms   *sync.Map          // JARM指纹缓存(host:port -> 指纹), 多目标共享
 
 	// 添加内存控制相关字段
-	maxConcurrent   int   // 最大并发数
-	maxResponseSize int64 // 最大响应大小
+	maxConcurrent int // 最大并发数
 }
 
 // NewFinger 创建Finger对象
 // 参数:
 //   - probes: 探针配置
-//   - tags: 指纹标签
+//   - rules: 编译后的指纹规则
 //   - logger: 日志对象
 //
 // 返回值:
 //   - *Finger: 新创建的Finger实例
-func NewFinger(probes *pkg.Probes, tags *pkg.Tags, logger *logger.Logger) *Finger {
+func NewFinger(probes *pkg.Probes, rules *pkg.RuleSet, logger *logger.Logger) *Finger {
 	return &Finger{
 		probes: probes,
-		tags:   tags,
+		rules:  rules,
 		logger: logger,
 		Result: make([]string, 0),
+		jarms:  &sync.Map{},
+	}
+}
+
+// SetOptions 设置可选功能
+// 参数:
+//   - options: 可选功能配置
+func (f *Finger) SetOptions(options Options) {
+	if options.JARMTimeout <= 0 {
+		options.JARMTimeout = 5 * time.Second
+	}
+	if options.ClientRedirectDepth <= 0 {
+		options.ClientRedirectDepth = 3
 	}
+	f.options = options
+	f.maxConcurrent = options.Threads
 }
 
 // Run 运行单个URL的指纹识别
@@ -57,8 +94,34 @@ func (f *Finger) Run(url string) {
 	if finger, err := f.finger(url); err != nil {
 		f.logger.Warnf("指纹识别失败: %v", err)
 	} else {
-		f.logger.Success(finger.Result, finger.Url, finger.Title)
+		f.logger.Success(finger.labels(), finger.Url, finger.Title)
+		if finger.Cert != nil {
+			f.logger.Infof("TLS证书: %s", finger.Cert)
+		}
+		if finger.JARM != "" {
+			f.logger.Infof("JARM指纹: %s", finger.JARM)
+		}
+		if len(finger.Redirects) > 0 {
+			f.logger.Infof("客户端跳转: %s", strings.Join(finger.Redirects, " -> "))
+		}
+		if finger.FinalURL != "" && strings.TrimSuffix(finger.FinalURL, "/") != strings.TrimSuffix(finger.Url, "/") {
+			f.logger.Infof("最终URL: %s", finger.FinalURL)
+		}
+	}
+}
+
+// labels 生成输出使用的指纹标签, 置信度低于1的指纹附带百分比
+// 返回值:
+//   - []string: 指纹标签
+func (f *Finger) labels() []string {
+	labels := make([]string, 0, len(f.Result))
+	for _, tag := range f.Result {
+		if c, ok := f.Confidence[tag]; ok && c < 1 {
+			tag = fmt.Sprintf("%s(%.0f%%)", tag, c*100)
+		}
+		labels = append(labels, tag)
 	}
+	return labels
 }
 
 // RunAsync 异步执行多URL指纹识别
@@ -86,7 +149,17 @@ func (f *Finger) finger(url string) (*Finger, error) {
 		return nil, fmt.Errorf("参数验证失败: %v", err)
 	}
 
+	// 清除上一个目标的结果, 复用的Finger不能沿用上次的证书与JARM指纹
 	f.Url = url
+	f.Result = make([]string, 0)
+	f.Confidence = nil
+	f.Title = ""
+	f.favicon = ""
+	f.Cert = nil
+	f.JARM = ""
+	f.Redirects = nil
+	f.FinalURL = ""
+	f.cache = pkg.NewResponseCache()
 	if !f.async {
 		f.logger.Infof("探针服务启动成功!")
 		f.logger.Debugf("处理URL: %s", url)
@@ -102,6 +175,26 @@ func (f *Finger) finger(url string) (*Finger, error) {
 		f.logger.Infof("指纹识别服务启动成功!")
 	}
 
+	// 记录TLS证书摘要
+	for _, resp := range resps {
+		if cert := resp.CertInfo(); cert != nil {
+			f.Cert = cert
+			break
+		}
+	}
+
+	// 计算JARM指纹
+	if f.options.JARM {
+		if hash, err := f.getJARM(url); err != nil {
+			f.logger.Debugf("获取JARM指纹失败: %v", err)
+		} else if hash != "" {
+			f.JARM = hash
+			for _, resp := range resps {
+				resp.JARM = hash
+			}
+		}
+	}
+
 	// 获取favicon
 	favicon, err := f.getFavicon()
 	if err != nil {
@@ -138,13 +231,13 @@ func (f *Finger) validateParams(url string) error {
 	if f.probes == nil {
 		return fmt.Errorf("探针配置不能为空")
 	}
-	if f.tags == nil {
-		return fmt.Errorf("指纹标签不能为空")
+	if f.rules == nil {
+		return fmt.Errorf("指纹规则不能为空")
 	}
 	return nil
 }
 
-// sendProbeRequests 并发发送探针请求
+// sendProbeRequests 并发发送探针请求与规则自定义的请求
 // 参数:
 //   - url: 目标URL
 //
@@ -152,27 +245,36 @@ func (f *Finger) validateParams(url string) error {
 //   - []*pkg.HttpResponse: HTTP响应列表
 //   - error: 错误信息
 func (f *Finger) sendProbeRequests(url string) ([]*pkg.HttpResponse, error) {
+	// 规则自定义的请求沿用根路径探针的请求头与超时, 与探针相同的请求经缓存只发送一次
+	loadBuiltinProbes()
+	probes := make([]pkg.Probe, 0, len(f.probes.Probes)+len(f.rules.Requests()))
+	for _, probe := range f.probes.Probes {
+		// 跳过favicon探针, 由getFavicon单独请求
+		if probe.Desc != "favicon" {
+			probes = append(probes, probe)
+		}
+	}
+	for _, request := range f.rules.Requests() {
+		probes = append(probes, request.Probe(builtinTitle))
+	}
+
 	var wg sync.WaitGroup
-	results := make(chan *pkg.HttpResponse, len(f.probes.Probes))
-	errors := make(chan error, len(f.probes.Probes))
+	results := make(chan []*pkg.HttpResponse, len(probes))
+	errors := make(chan error, len(probes))
+	expectedCount := len(probes)
 
 	// 并发发送请求
-	for _, probe := range f.probes.Probes {
+	for _, probe := range probes {
 		wg.Add(1)
-
-		// 跳过favicon探针
-		if probe.Desc == "favicon" {
-			continue
-		}
-
 		go func(p pkg.Probe) {
 			defer wg.Done()
-			resp, err := f.probes.HttpRequest(url, p)
+			resp, err := f.probes.CachedRequest(f.cache, url, p)
 			if err != nil {
 				errors <- fmt.Errorf("探针请求失败: %v", err)
 				return
 			}
-			results <- resp
+			// 客户端跳转的每一跳都参与指纹匹配
+			results <- append([]*pkg.HttpResponse{resp}, f.followClientRedirects(resp, p)...)
 		}(probe)
 	}
 
@@ -183,22 +285,22 @@ func (f *Finger) sendProbeRequests(url string) ([]*pkg.HttpResponse, error) {
 		close(errors)
 	}()
 
-	return f.collectResponses(results, errors)
+	return f.collectResponses(results, errors, expectedCount)
 }
 
 // collectResponses 收集HTTP响应
 // 参数:
 //   - results: 探针结果通道
 //   - errors: 错误通道
+//   - expectedCount: 预期的响应数量
 //
 // 返回值:
 //   - []*pkg.HttpResponse: HTTP响应列表
 //   - error: 错误信息
-func (f *Finger) collectResponses(results chan *pkg.HttpResponse, errors chan error) ([]*pkg.HttpResponse, error) {
+func (f *Finger) collectResponses(results chan []*pkg.HttpResponse, errors chan error, expectedCount int) ([]*pkg.HttpResponse, error) {
 	var resps []*pkg.HttpResponse
 	timeout := time.After(30 * time.Second)
 	respCount := 0
-	expectedCount := len(f.probes.Probes)
 
 collectLoop:
 	for respCount < expectedCount {
@@ -209,11 +311,11 @@ collectLoop:
 			}
 			f.logger.Debugf(err.Error())
 			respCount++
-		case resp, ok := <-results:
+		case chain, ok := <-results:
 			if !ok {
 				break collectLoop
 			}
-			resps = append(resps, resp)
+			resps = append(resps, chain...)
 			respCount++
 		case <-timeout:
 			f.logger.Debugf("请求超时,开始处理已收到的响应")
@@ -240,7 +342,7 @@ collectLoop:
 //   - error: 错误信息
 func (f *Finger) matchFingerprints(resps []*pkg.HttpResponse) error {
 	var matchWg sync.WaitGroup
-	matchResults := make(chan []string, len(resps))
+	matchResults := make(chan []pkg.Detection, len(resps))
 	matchErrors := make(chan error, len(resps))
 
 	// 并发匹配
@@ -248,7 +350,7 @@ func (f *Finger) matchFingerprints(resps []*pkg.HttpResponse) error {
 		matchWg.Add(1)
 		go func(r *pkg.HttpResponse) {
 			defer matchWg.Done()
-			matchedTags, err := match.Match(r, f.tags, f.favicon, f.logger)
+			matchedTags, err := match.Detect(r, f.rules, f.favicon, f.logger)
 			if err != nil {
 				matchErrors <- fmt.Errorf("匹配失败: %v", err)
 				return
@@ -276,8 +378,9 @@ func (f *Finger) matchFingerprints(resps []*pkg.HttpResponse) error {
 //
 // 返回值:
 //   - error: 错误信息
-func (f *Finger) processMatchResults(matchResults chan []string, matchErrors chan error) error {
-	tagMap := make(map[string]struct{}, 32)
+func (f *Finger) processMatchResults(matchResults chan []pkg.Detection, matchErrors chan error) error {
+	// 同一指纹在多个响应中命中时取最高置信度
+	confidence := make(map[string]float64, 32)
 	errCount := 0
 
 	// 使用WaitGroup等待所有结果处理完成
@@ -291,10 +394,12 @@ func (f *Finger) processMatchResults(matchResults chan []string, matchErrors cha
 		for matchedTags := range matchResults {
 			for _, tag := range matchedTags {
 				mu.Lock()
-				if _, exists := tagMap[tag]; !exists {
-					tagMap[tag] = struct{}{}
-					f.logger.Debugf("匹配到指纹: %s", tag)
-					f.Result = append(f.Result, tag)
+				if current, exists := confidence[tag.Name]; !exists {
+					f.logger.Debugf("匹配到指纹: %s", tag.Name)
+					f.Result = append(f.Result, tag.Name)
+					confidence[tag.Name] = tag.Confidence
+				} else if tag.Confidence > current {
+					confidence[tag.Name] = tag.Confidence
 				}
 				mu.Unlock()
 			}
@@ -312,6 +417,8 @@ func (f *Finger) processMatchResults(matchResults chan []string, matchErrors cha
 
 	wg.Wait()
 
+	f.resolveResults(confidence)
+
 	if len(f.Result) == 0 {
 		f.logger.Debugf("未匹配到指纹")
 		return fmt.Errorf("未匹配到指纹")
@@ -323,11 +430,114 @@ func (f *Finger) processMatchResults(matchResults chan []string, matchErrors cha
 	return nil
 }
 
-// extractTitle 提取标题
+// resolveResults 按最低置信度筛选匹配结果, 再按规则间的推断、互斥与依赖关系整理.
+// 低于最低置信度的指纹先被忽略, 不能再剔除其他指纹或推断出新指纹
+// 参数:
+//   - confidence: 匹配到的指纹及其置信度, 推断得到的指纹写入其中
+func (f *Finger) resolveResults(confidence map[string]float64) {
+	detected := make([]string, 0, len(f.Result))
+	for _, tag := range f.Result {
+		if confidence[tag] < f.options.MinConfidence {
+			f.logger.Debugf("指纹 %s 的置信度 %.2f 低于 %.2f, 已忽略", tag, confidence[tag], f.options.MinConfidence)
+			continue
+		}
+		detected = append(detected, tag)
+	}
+
+	resolved, removed := f.rules.Resolve(detected)
+	for _, tag := range removed {
+		f.logger.Debugf("剔除指纹: %s", tag)
+	}
+	for _, tag := range resolved[len(detected)-len(removed):] {
+		f.logger.Debugf("推断出指纹: %s", tag)
+	}
+	f.rules.InferConfidence(resolved, confidence)
+
+	// 每次生成新的结果与置信度映射, 避免复用的Finger共享结果
+	f.Result = make([]string, 0, len(resolved))
+	f.Confidence = make(map[string]float64, len(resolved))
+	for _, tag := range resolved {
+		f.Result = append(f.Result, tag)
+		f.Confidence[tag] = confidence[tag]
+	}
+}
+
+// followClientRedirects 跟随meta refresh与JavaScript跳转, 跳转请求同样经过响应缓存
+// 参数:
+//   - resp: 起始响应
+//   - probe: 起始探针, 跳转请求沿用其请求头
+//
+// 返回值:
+//   - []*pkg.HttpResponse: 依次跳转得到的响应(不含起始响应)
+func (f *Finger) followClientRedirects(resp *pkg.HttpResponse, probe pkg.Probe) []*pkg.HttpResponse {
+	if !f.options.ClientRedirect {
+		return nil
+	}
+
+	var hops []*pkg.HttpResponse
+	visited := map[string]struct{}{resp.URL: {}}
+	current := resp
+	for depth := 0; depth < f.options.ClientRedirectDepth; depth++ {
+		target, ok := pkg.ClientRedirect(current)
+		if !ok {
+			break
+		}
+		if _, seen := visited[target]; seen {
+			break
+		}
+		visited[target] = struct{}{}
+
+		base, path, err := pkg.SplitURL(target)
+		if err != nil {
+			break
+		}
+		next, err := f.probes.CachedRequest(f.cache, base, pkg.RedirectProbe(probe, path))
+		if err != nil {
+			f.logger.Debugf("跟随客户端跳转 %s 失败: %v", target, err)
+			break
+		}
+		hops = append(hops, next)
+		current = next
+	}
+	return hops
+}
+
+// 内置的标题与favicon探针, 首次使用时解析
+var (
+	builtinProbesOnce sync.Once
+	builtinTitle      pkg.Probe
+	builtinFavicon    pkg.Probe
+)
+
+// loadBuiltinProbes 解析内置的标题与favicon探针
+func loadBuiltinProbes() {
+	builtinProbesOnce.Do(func() {
+		builtinTitle = utils.ProbesContent2ProbesStruct(utils.ProbesForGetTitle).Probes["093561eda8a835f5a01738826c77dbf6"]
+		builtinFavicon = utils.ProbesContent2ProbesStruct(utils.ProbesForGetFavicon).Probes["favicon"]
+	})
+}
+
+// extractTitle 提取标题并记录最终URL, 与根路径探针共用缓存的响应
 // 返回值:
 //   - error: 错误信息
 func (f *Finger) extractTitle() error {
-	title, err := match.MathTitle(f.probes, f.Url)
+	loadBuiltinProbes()
+	resp, err := f.probes.CachedRequest(f.cache, f.Url, builtinTitle)
+	if err != nil {
+		return fmt.Errorf("获取页面内容失败: %v", err)
+	}
+
+	// 根路径存在客户端跳转时, 使用最终落地页的标题
+	hops := f.followClientRedirects(resp, builtinTitle)
+	for _, hop := range hops {
+		f.Redirects = append(f.Redirects, hop.URL)
+	}
+	if len(hops) > 0 {
+		resp = hops[len(hops)-1]
+	}
+	f.FinalURL = resp.URL
+
+	title, err := match.MathTitle(resp)
 	if err != nil {
 		return fmt.Errorf("提取标题失败: %v", err)
 	}
@@ -335,18 +545,72 @@ func (f *Finger) extractTitle() error {
 	return nil
 }
 
-// getFavicon 获取favicon
+// getFavicon 获取favicon, 优先使用探针集中的favicon探针
 // 返回值:
 //   - string: favicon
 //   - error: 错误信息
 func (f *Finger) getFavicon() (string, error) {
-	favicon, err := match.MatchFavicon(f.probes, f.Url)
+	loadBuiltinProbes()
+	probe := builtinFavicon
+	for _, p := range f.probes.Probes {
+		if p.Desc == "favicon" {
+			probe = p
+			break
+		}
+	}
+
+	resp, err := f.probes.CachedRequest(f.cache, f.Url, probe)
+	if err != nil {
+		return "", fmt.Errorf("获取页面内容失败: %v", err)
+	}
+
+	favicon, err := match.MatchFavicon(resp)
 	if err != nil {
 		return "", fmt.Errorf("获取favicon失败: %v", err)
 	}
 	return favicon, nil
 }
 
+// getJARM 获取HTTPS目标的JARM指纹, 同一host:port只探测一次
+// 参数:
+//   - target: 目标URL
+//
+// 返回值:
+//   - string: JARM指纹, 非HTTPS目标返回空
+//   - error: 错误信息
+func (f *Finger) getJARM(target string) (string, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", fmt.Errorf("解析URL失败: %v", err)
+	}
+	if u.Scheme != "https" {
+		return "", nil
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = "443"
+	}
+	addr := net.JoinHostPort(u.Hostname(), port)
+	if cached, ok := f.jarms.Load(addr); ok {
+		return cached.(string), nil
+	}
+
+	// 与HTTP请求使用相同的代理, 避免JARM探测绕过代理直连
+	dial, err := f.probes.Options.Dialer(f.options.JARMTimeout)
+	if err != nil {
+		return "", err
+	}
+	// SNI与HTTP请求的TLS配置保持一致
+	sni := f.probes.Options.SNI(u.Hostname())
+	hash, err := jarm.FingerprintWithDialer(u.Hostname(), port, sni, f.options.JARMTimeout, jarm.DialFunc(dial))
+	if err != nil {
+		return "", err
+	}
+	f.jarms.Store(addr, hash)
+	return hash, nil
+}
+
 // fingerAsync 异步处理多个URL的指纹识别
 // 参数:
 //   - filePath: 目标文件路径
@@ -380,13 +644,14 @@ func (f *Finger) fingerAsync(filePath string) ([]Finger, error) {
 	fingerPool := sync.Pool{
 		New: func() interface{} {
 			return &Finger{
-				probes:          f.probes, // 复用探针配置
-				tags:            f.tags,   // 复用指纹标签
-				logger:          f.logger, // 复用日志对象
-				maxConcurrent:   100,
-				maxResponseSize: 10 * 1024 * 1024,  // 10MB
-				Result:          make([]string, 0), // 每次需要新的结果集
-				async:           true,              // 异步模式
+				probes:        f.probes, // 复用探针配置
+				rules:         f.rules,  // 复用编译后的指纹规则
+				logger:        f.logger, // 复用日志对象
+				maxConcurrent: 100,
+				Result:        make([]string, 0), // 每次需要新的结果集
+				async:         true,              // 异步模式
+				options:       f.options,         // 复用可选功能配置
+				jarms:         f.jarms,           // 共享JARM指纹缓存
 			}
 		},
 	}
@@ -409,9 +674,12 @@ func (f *Finger) fingerAsync(filePath string) ([]Finger, error) {
 			defer func() { <-semaphore }()
 
 			finger := fingerPool.Get().(*Finger)
-			finger.Result = finger.Result[:0]
+			finger.Result = make([]string, 0) // 结果已复制到results, 不能复用底层数组
+			finger.Confidence = nil
 			finger.Url = u
 			finger.Title = ""
+			finger.Cert = nil
+			finger.JARM = ""
 
 			if !strings.HasPrefix(u, "http://") && !strings.HasPrefix(u, "https://") {
 				u = "http://" + u
@@ -425,7 +693,7 @@ func (f *Finger) fingerAsync(filePath string) ([]Finger, error) {
 					atomic.AddUint32(&failCount, 1)
 				}
 			} else {
-				finger.logger.Success(f.Result, f.Url, f.Title)
+				finger.logger.Success(f.labels(), f.Url, f.Title)
 				atomic.AddUint32(&successCount, 1)
 				mu.Lock()
 				results = append(results, *f)
@@ -0,0 +1,111 @@
+package finger
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/enenisme/definger/logger"
+	"github.com/enenisme/definger/pkg"
+)
+
+func TestResolveResultsMinConfidence(t *testing.T) {
+	tags := []pkg.Tag{
+		{ID: "low", Info: pkg.Infos{Name: "Low", Excludes: []string{"High"}, Implies: []string{"Runtime"}}},
+		{ID: "high", Info: pkg.Infos{Name: "High"}},
+	}
+	f := &Finger{
+		Result:  []string{"Low", "High"},
+		rules:   pkg.NewRuleSet(tags),
+		logger:  &logger.Logger{Level: logger.LogLevelError},
+		options: Options{MinConfidence: 0.5},
+	}
+
+	// 低于最低置信度的指纹不能剔除互斥的高置信度指纹, 也不能推断出新指纹
+	f.resolveResults(map[string]float64{"Low": 0.3, "High": 1})
+	assert.Equal(t, []string{"High"}, f.Result)
+	assert.Equal(t, map[string]float64{"High": 1}, f.Confidence)
+
+	// 不设置最低置信度时双方互斥, 全部剔除
+	f.Result, f.options.MinConfidence = []string{"Low", "High"}, 0
+	f.resolveResults(map[string]float64{"Low": 0.3, "High": 1})
+	assert.Empty(t, f.Result)
+}
+
+func TestRuleRequests(t *testing.T) {
+	// 只有带指定请求头与请求体的POST请求才返回产品特征
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Method == http.MethodPost && r.URL.Path == "/api/login" &&
+			r.Header.Get("X-Requested-With") == "XMLHttpRequest" && string(body) == `{"user":"admin"}` {
+			w.Write([]byte(`{"code":401,"product":"acme-sso"}`))
+			return
+		}
+		w.Write([]byte("<html><title>Home</title></html>"))
+	}))
+	defer server.Close()
+
+	tags := []pkg.Tag{{ID: "acme-sso", Info: pkg.Infos{Name: "Acme SSO"}, HTTP: []pkg.HTTP{{
+		Method:   "POST",
+		Path:     []string{"/api/login"},
+		Headers:  map[string]string{"X-Requested-With": "XMLHttpRequest"},
+		Body:     `{"user":"admin"}`,
+		Matchers: []pkg.Matchers{{Type: "word", Part: "body", Words: []string{"acme-sso"}}},
+	}}}}
+	probes := &pkg.Probes{Probes: map[string]pkg.Probe{
+		"root": {Data: "GET / HTTP/1.1\r\nAccept: */*\r\n\r\n", Timeout: 5},
+	}}
+	f := NewFinger(probes, pkg.NewRuleSet(tags), &logger.Logger{Level: logger.LogLevelError})
+	f.SetOptions(Options{})
+
+	result, err := f.finger(server.URL)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Acme SSO"}, result.Result)
+}
+
+func TestJARMServerName(t *testing.T) {
+	var mu sync.Mutex
+	names := make(map[string]bool)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		mu.Lock()
+		names[hello.ServerName] = true
+		mu.Unlock()
+		return nil, nil
+	}}
+	server.StartTLS()
+	defer server.Close()
+	target := fmt.Sprintf("https://localhost:%d", server.Listener.Addr().(*net.TCPAddr).Port)
+
+	for _, tc := range []struct {
+		profile *pkg.TLSProfile
+		want    string
+	}{
+		{nil, "localhost"},
+		{&pkg.TLSProfile{ServerName: "portal.example.com"}, "portal.example.com"},
+		{&pkg.TLSProfile{ServerName: "portal.example.com", DisableSNI: true}, ""},
+	} {
+		mu.Lock()
+		clear(names)
+		mu.Unlock()
+
+		f := NewFinger(&pkg.Probes{Options: &pkg.HTTPOptions{TLS: tc.profile}}, pkg.NewRuleSet(nil), &logger.Logger{Level: logger.LogLevelError})
+		f.SetOptions(Options{JARMTimeout: 2 * time.Second})
+		_, err := f.getJARM(target)
+		require.NoError(t, err)
+
+		// 10个探测均使用与HTTP请求一致的SNI
+		mu.Lock()
+		assert.Equal(t, map[string]bool{tc.want: true}, names, tc.want)
+		mu.Unlock()
+	}
+}
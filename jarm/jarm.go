@@ -0,0 +1,474 @@
+package jarm
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// randReader ClientHello中随机字段与GREASE的随机源, 测试时替换为固定内容
+var randReader io.Reader = rand.Reader
+
+// 空指纹, 所有探测均无响应时返回
+const emptyHash = "00000000000000000000000000000000000000000000000000000000000000"
+
+// probe 定义单个ClientHello探测的参数
+type probe struct {
+	version        string // TLS版本: TLS_1.1, TLS_1.2, TLS_1.3
+	cipherList     string // 加密套件列表: ALL, NO1.3
+	cipherOrder    string // 加密套件顺序: FORWARD, REVERSE, TOP_HALF, BOTTOM_HALF, MIDDLE_OUT
+	grease         bool   // 是否添加GREASE
+	rareALPN       bool   // 是否使用罕见ALPN
+	support        string // 版本支持扩展: 1.2_SUPPORT, 1.3_SUPPORT, NO_SUPPORT
+	extensionOrder string // 扩展顺序
+}
+
+// probes JARM固定的10个探测, 顺序影响最终指纹
+var probes = []probe{
+	{"TLS_1.2", "ALL", "FORWARD", false, false, "1.2_SUPPORT", "REVERSE"},
+	{"TLS_1.2", "ALL", "REVERSE", false, false, "1.2_SUPPORT", "FORWARD"},
+	{"TLS_1.2", "ALL", "TOP_HALF", false, false, "NO_SUPPORT", "FORWARD"},
+	{"TLS_1.2", "ALL", "BOTTOM_HALF", false, true, "NO_SUPPORT", "FORWARD"},
+	{"TLS_1.2", "ALL", "MIDDLE_OUT", true, true, "NO_SUPPORT", "REVERSE"},
+	{"TLS_1.1", "ALL", "FORWARD", false, false, "NO_SUPPORT", "FORWARD"},
+	{"TLS_1.3", "ALL", "FORWARD", false, false, "1.3_SUPPORT", "REVERSE"},
+	{"TLS_1.3", "ALL", "REVERSE", false, false, "1.3_SUPPORT", "FORWARD"},
+	{"TLS_1.3", "NO1.3", "FORWARD", false, false, "1.3_SUPPORT", "FORWARD"},
+	{"TLS_1.3", "ALL", "MIDDLE_OUT", true, false, "1.3_SUPPORT", "REVERSE"},
+}
+
+// allCiphers 全部加密套件
+var allCiphers = []uint16{
+	0x0016, 0x0033, 0x0067, 0xc09e, 0xc0a2, 0x009e, 0x0039, 0x006b, 0xc09f, 0xc0a3,
+	0x009f, 0x0045, 0x00be, 0x0088, 0x00c4, 0x009a, 0xc008, 0xc009, 0xc023, 0xc0ac,
+	0xc0ae, 0xc02b, 0xc00a, 0xc024, 0xc0ad, 0xc0af, 0xc02c, 0xc072, 0xc073, 0xcca9,
+	0x1302, 0x1301, 0xcc14, 0xc007, 0xc012, 0xc013, 0xc027, 0xc02f, 0xc014, 0xc028,
+	0xc030, 0xc060, 0xc061, 0xc076, 0xc077, 0xcca8, 0x1305, 0x1304, 0x1303, 0xcc13,
+	0xc011, 0x000a, 0x002f, 0x003c, 0xc09c, 0xc0a0, 0x009c, 0x0035, 0x003d, 0xc09d,
+	0xc0a1, 0x009d, 0x0041, 0x00ba, 0x0084, 0x00c0, 0x0007, 0x0004, 0x0005,
+}
+
+// hashCiphers 用于将服务端选择的加密套件压缩为两位十六进制的顺序表
+var hashCiphers = []uint16{
+	0x0004, 0x0005, 0x0007, 0x000a, 0x0016, 0x002f, 0x0033, 0x0035, 0x0039, 0x003c,
+	0x003d, 0x0041, 0x0045, 0x0067, 0x006b, 0x0084, 0x0088, 0x009a, 0x009c, 0x009d,
+	0x009e, 0x009f, 0x00ba, 0x00be, 0x00c0, 0x00c4, 0xc007, 0xc008, 0xc009, 0xc00a,
+	0xc011, 0xc012, 0xc013, 0xc014, 0xc023, 0xc024, 0xc027, 0xc028, 0xc02b, 0xc02c,
+	0xc02f, 0xc030, 0xc060, 0xc061, 0xc072, 0xc073, 0xc076, 0xc077, 0xc09c, 0xc09d,
+	0xc09e, 0xc09f, 0xc0a0, 0xc0a1, 0xc0a2, 0xc0a3, 0xc0ac, 0xc0ad, 0xc0ae, 0xc0af,
+	0xcc13, 0xcc14, 0xcca8, 0xcca9, 0x1301, 0x1302, 0x1303, 0x1304, 0x1305,
+}
+
+// DialFunc 定义建立TCP连接的函数
+type DialFunc func(network, addr string) (net.Conn, error)
+
+// Fingerprint 计算目标的JARM指纹
+// 参数:
+//   - host: 目标主机(同时作为SNI)
+//   - port: 目标端口
+//   - timeout: 单次探测超时时间
+//
+// 返回:
+//   - string: 62位JARM指纹
+//   - error: 错误信息
+func Fingerprint(host, port string, timeout time.Duration) (string, error) {
+	dialer := &net.Dialer{Timeout: timeout}
+	return FingerprintWithDialer(host, port, host, timeout, dialer.Dial)
+}
+
+// FingerprintWithDialer 使用指定的拨号函数计算目标的JARM指纹
+// 参数:
+//   - host: 目标主机
+//   - port: 目标端口
+//   - serverName: ClientHello中的SNI, 为空时不发送server_name扩展
+//   - timeout: 单次探测超时时间
+//   - dial: 拨号函数
+//
+// 返回:
+//   - string: 62位JARM指纹
+//   - error: 错误信息
+func FingerprintWithDialer(host, port, serverName string, timeout time.Duration, dial DialFunc) (string, error) {
+	addr := net.JoinHostPort(host, port)
+	raws := make([]string, 0, len(probes))
+	failed := 0
+
+	for _, p := range probes {
+		raw, err := sendProbe(addr, serverName, p, timeout, dial)
+		if err != nil {
+			failed++
+		}
+		raws = append(raws, raw)
+	}
+
+	// 全部探测都无法建立连接时视为失败
+	if failed == len(probes) {
+		return "", fmt.Errorf("JARM探测 %s 失败: 无法建立连接", addr)
+	}
+
+	return hashRaw(strings.Join(raws, ",")), nil
+}
+
+// sendProbe 发送单个ClientHello并解析ServerHello
+// 参数:
+//   - addr: 目标地址
+//   - host: SNI主机名, 为空时不发送SNI
+//   - p: 探测参数
+//   - timeout: 超时时间
+//   - dial: 拨号函数
+//
+// 返回:
+//   - string: 原始探测结果(cipher|version|alpn|extensions)
+//   - error: 连接错误
+func sendProbe(addr, host string, p probe, timeout time.Duration, dial DialFunc) (string, error) {
+	conn, err := dial("tcp", addr)
+	if err != nil {
+		return "|||", err
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(timeout))
+	if _, err := conn.Write(buildClientHello(host, p)); err != nil {
+		return "|||", nil
+	}
+
+	buf := make([]byte, 1484)
+	n, _ := conn.Read(buf)
+	return parseServerHello(buf[:n]), nil
+}
+
+// buildClientHello 构造探测使用的ClientHello报文
+func buildClientHello(host string, p probe) []byte {
+	var recordVersion, helloVersion []byte
+	switch p.version {
+	case "TLS_1.3":
+		recordVersion, helloVersion = []byte{0x03, 0x01}, []byte{0x03, 0x03}
+	case "TLS_1.1":
+		recordVersion, helloVersion = []byte{0x03, 0x02}, []byte{0x03, 0x02}
+	default:
+		recordVersion, helloVersion = []byte{0x03, 0x03}, []byte{0x03, 0x03}
+	}
+
+	hello := append([]byte{}, helloVersion...)
+	hello = append(hello, randomBytes(32)...)
+	hello = append(hello, 32)
+	hello = append(hello, randomBytes(32)...)
+
+	ciphers := buildCiphers(p)
+	hello = appendUint16(hello, uint16(len(ciphers)))
+	hello = append(hello, ciphers...)
+	hello = append(hello, 0x01, 0x00) // 压缩方法: null
+	hello = append(hello, buildExtensions(host, p)...)
+
+	handshake := []byte{0x01, 0x00}
+	handshake = appendUint16(handshake, uint16(len(hello)))
+	handshake = append(handshake, hello...)
+
+	payload := append([]byte{0x16}, recordVersion...)
+	payload = appendUint16(payload, uint16(len(handshake)))
+	return append(payload, handshake...)
+}
+
+// buildCiphers 按探测参数生成加密套件列表
+func buildCiphers(p probe) []byte {
+	list := make([][]byte, 0, len(allCiphers))
+	for _, c := range allCiphers {
+		if p.cipherList == "NO1.3" && c>>8 == 0x13 {
+			continue
+		}
+		list = append(list, appendUint16(nil, c))
+	}
+	if p.cipherOrder != "FORWARD" {
+		list = mung(list, p.cipherOrder)
+	}
+	if p.grease {
+		list = append([][]byte{randomGrease()}, list...)
+	}
+	return concat(list)
+}
+
+// buildExtensions 按探测参数生成扩展字段
+func buildExtensions(host string, p probe) []byte {
+	var ext []byte
+	if p.grease {
+		ext = append(ext, randomGrease()...)
+		ext = append(ext, 0x00, 0x00)
+	}
+
+	// server_name, 禁用SNI时省略
+	if host != "" {
+		ext = append(ext, 0x00, 0x00)
+		ext = appendUint16(ext, uint16(len(host)+5))
+		ext = appendUint16(ext, uint16(len(host)+3))
+		ext = append(ext, 0x00)
+		ext = appendUint16(ext, uint16(len(host)))
+		ext = append(ext, host...)
+	}
+
+	ext = append(ext, 0x00, 0x17, 0x00, 0x00)                                                             // extended_master_secret
+	ext = append(ext, 0x00, 0x01, 0x00, 0x01, 0x01)                                                       // max_fragment_length
+	ext = append(ext, 0xff, 0x01, 0x00, 0x01, 0x00)                                                       // renegotiation_info
+	ext = append(ext, 0x00, 0x0a, 0x00, 0x0a, 0x00, 0x08, 0x00, 0x1d, 0x00, 0x17, 0x00, 0x18, 0x00, 0x19) // supported_groups
+	ext = append(ext, 0x00, 0x0b, 0x00, 0x02, 0x01, 0x00)                                                 // ec_point_formats
+	ext = append(ext, 0x00, 0x23, 0x00, 0x00)                                                             // session_ticket
+	ext = append(ext, buildALPN(p)...)
+	ext = append(ext, 0x00, 0x0d, 0x00, 0x14, 0x00, 0x12, 0x04, 0x03, 0x08, 0x04, 0x04, 0x01,
+		0x05, 0x03, 0x08, 0x05, 0x05, 0x01, 0x08, 0x06, 0x06, 0x01, 0x02, 0x01) // signature_algorithms
+	ext = append(ext, buildKeyShare(p.grease)...)
+	ext = append(ext, 0x00, 0x2d, 0x00, 0x02, 0x01, 0x01) // psk_key_exchange_modes
+
+	if p.version == "TLS_1.3" || p.support == "1.2_SUPPORT" {
+		ext = append(ext, buildSupportedVersions(p)...)
+	}
+
+	return append(appendUint16(nil, uint16(len(ext))), ext...)
+}
+
+// buildALPN 生成ALPN扩展
+func buildALPN(p probe) []byte {
+	var alpns [][]byte
+	if p.rareALPN {
+		alpns = [][]byte{
+			[]byte("\x08http/0.9"), []byte("\x08http/1.0"), []byte("\x06spdy/1"),
+			[]byte("\x06spdy/2"), []byte("\x06spdy/3"), []byte("\x03h2c"), []byte("\x02hq"),
+		}
+	} else {
+		alpns = [][]byte{
+			[]byte("\x08http/0.9"), []byte("\x08http/1.0"), []byte("\x08http/1.1"),
+			[]byte("\x06spdy/1"), []byte("\x06spdy/2"), []byte("\x06spdy/3"),
+			[]byte("\x02h2"), []byte("\x03h2c"), []byte("\x02hq"),
+		}
+	}
+	if p.extensionOrder != "FORWARD" {
+		alpns = mung(alpns, p.extensionOrder)
+	}
+
+	all := concat(alpns)
+	ext := []byte{0x00, 0x10}
+	ext = appendUint16(ext, uint16(len(all)+2))
+	ext = appendUint16(ext, uint16(len(all)))
+	return append(ext, all...)
+}
+
+// buildKeyShare 生成key_share扩展
+func buildKeyShare(grease bool) []byte {
+	var share []byte
+	if grease {
+		share = append(share, randomGrease()...)
+		share = append(share, 0x00, 0x01, 0x00)
+	}
+	share = append(share, 0x00, 0x1d, 0x00, 0x20)
+	share = append(share, randomBytes(32)...)
+
+	ext := []byte{0x00, 0x33}
+	ext = appendUint16(ext, uint16(len(share)+2))
+	ext = appendUint16(ext, uint16(len(share)))
+	return append(ext, share...)
+}
+
+// buildSupportedVersions 生成supported_versions扩展
+func buildSupportedVersions(p probe) []byte {
+	versions := [][]byte{{0x03, 0x01}, {0x03, 0x02}, {0x03, 0x03}}
+	if p.support != "1.2_SUPPORT" {
+		versions = append(versions, []byte{0x03, 0x04})
+	}
+	if p.extensionOrder != "FORWARD" {
+		versions = mung(versions, p.extensionOrder)
+	}
+
+	var all []byte
+	if p.grease {
+		all = append(all, randomGrease()...)
+	}
+	all = append(all, concat(versions)...)
+
+	ext := []byte{0x00, 0x2b}
+	ext = appendUint16(ext, uint16(len(all)+1))
+	ext = append(ext, byte(len(all)))
+	return append(ext, all...)
+}
+
+// mung 按指定方式重排列表
+func mung(items [][]byte, order string) [][]byte {
+	n := len(items)
+	var out [][]byte
+	switch order {
+	case "REVERSE":
+		for i := n - 1; i >= 0; i-- {
+			out = append(out, items[i])
+		}
+	case "BOTTOM_HALF":
+		if n%2 == 1 {
+			out = append(out, items[n/2+1:]...)
+		} else {
+			out = append(out, items[n/2:]...)
+		}
+	case "TOP_HALF":
+		if n%2 == 1 {
+			out = append(out, items[n/2])
+		}
+		out = append(out, mung(mung(items, "REVERSE"), "BOTTOM_HALF")...)
+	case "MIDDLE_OUT":
+		middle := n / 2
+		if n%2 == 1 {
+			out = append(out, items[middle])
+			for i := 1; i <= middle; i++ {
+				out = append(out, items[middle+i], items[middle-i])
+			}
+		} else {
+			for i := 1; i <= middle; i++ {
+				out = append(out, items[middle-1+i], items[middle-i])
+			}
+		}
+	default:
+		out = items
+	}
+	return out
+}
+
+// parseServerHello 解析ServerHello, 返回 cipher|version|alpn|extensions
+func parseServerHello(data []byte) (raw string) {
+	defer func() {
+		// 畸形报文直接视为无响应
+		if recover() != nil {
+			raw = "|||"
+		}
+	}()
+
+	if len(data) == 0 || data[0] == 21 {
+		return "|||"
+	}
+	if !(data[0] == 22 && data[5] == 2) {
+		return "|||"
+	}
+
+	helloLength := int(binary.BigEndian.Uint16(data[3:5]))
+	counter := int(data[43])
+	cipher := hex.EncodeToString(data[counter+44 : counter+46])
+	version := hex.EncodeToString(data[9:11])
+	return cipher + "|" + version + "|" + parseExtensions(data, counter, helloLength)
+}
+
+// parseExtensions 解析ServerHello中的扩展, 返回 alpn|扩展类型列表
+func parseExtensions(data []byte, counter, helloLength int) (result string) {
+	defer func() {
+		if recover() != nil {
+			result = "|"
+		}
+	}()
+
+	if data[counter+47] == 11 {
+		return "|"
+	}
+	if string(data[counter+50:counter+53]) == "\x0e\xac\x0b" || string(data[82:85]) == "\x0f\xf0\x0b" {
+		return "|"
+	}
+	if counter+42 >= helloLength {
+		return "|"
+	}
+
+	count := 49 + counter
+	length := int(binary.BigEndian.Uint16(data[counter+47 : counter+49]))
+	maximum := length + count - 1
+
+	var types []string
+	alpn := ""
+	for count < maximum {
+		extType := data[count : count+2]
+		extLength := int(binary.BigEndian.Uint16(data[count+2 : count+4]))
+		var value []byte
+		if extLength == 0 {
+			count += 4
+		} else {
+			value = data[count+4 : count+4+extLength]
+			count += extLength + 4
+		}
+		if extType[0] == 0x00 && extType[1] == 0x10 && alpn == "" && len(value) > 3 {
+			alpn = string(value[3:])
+		}
+		types = append(types, hex.EncodeToString(extType))
+	}
+	return alpn + "|" + strings.Join(types, "-")
+}
+
+// hashRaw 将10个原始探测结果压缩为62位JARM指纹
+func hashRaw(raw string) string {
+	if raw == "|||,|||,|||,|||,|||,|||,|||,|||,|||,|||" {
+		return emptyHash
+	}
+
+	var fuzzy, alpnExt strings.Builder
+	for _, handshake := range strings.Split(raw, ",") {
+		components := strings.Split(handshake, "|")
+		if len(components) < 4 {
+			components = []string{"", "", "", ""}
+		}
+		fuzzy.WriteString(cipherByte(components[0]))
+		fuzzy.WriteString(versionByte(components[1]))
+		alpnExt.WriteString(components[2])
+		alpnExt.WriteString(components[3])
+	}
+
+	sum := sha256.Sum256([]byte(alpnExt.String()))
+	fuzzy.WriteString(hex.EncodeToString(sum[:])[:32])
+	return fuzzy.String()
+}
+
+// cipherByte 将加密套件转换为顺序表中的序号
+func cipherByte(cipher string) string {
+	if cipher == "" {
+		return "00"
+	}
+	count := 1
+	for _, c := range hashCiphers {
+		if fmt.Sprintf("%04x", c) == cipher {
+			break
+		}
+		count++
+	}
+	return fmt.Sprintf("%02x", count)
+}
+
+// versionByte 将TLS版本转换为单个字符
+func versionByte(version string) string {
+	if len(version) < 4 {
+		return "0"
+	}
+	idx := int(version[3] - '0')
+	if idx < 0 || idx > 5 {
+		return "0"
+	}
+	return string("abcdef"[idx])
+}
+
+// randomGrease 随机生成GREASE值
+func randomGrease() []byte {
+	b := randomBytes(1)[0]&0xf0 | 0x0a
+	return []byte{b, b}
+}
+
+// randomBytes 生成指定长度的随机字节
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	io.ReadFull(randReader, b)
+	return b
+}
+
+// appendUint16 以大端序追加uint16
+func appendUint16(b []byte, v uint16) []byte {
+	return binary.BigEndian.AppendUint16(b, v)
+}
+
+// concat 拼接字节切片列表
+func concat(items [][]byte) []byte {
+	var out []byte
+	for _, item := range items {
+		out = append(out, item...)
+	}
+	return out
+}
@@ -0,0 +1,103 @@
+package jarm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// zeroReader 输出全零的随机源, 使GREASE固定为0x0a0a
+type zeroReader struct{}
+
+func (zeroReader) Read(b []byte) (int, error) {
+	for i := range b {
+		b[i] = 0
+	}
+	return len(b), nil
+}
+
+// 以下期望值由参考实现(salesforce/jarm的jarm.py)在随机字节全零、GREASE固定为0x0a0a时生成
+
+func TestBuildClientHello(t *testing.T) {
+	defer func(r io.Reader) { randReader = r }(randReader)
+	randReader = zeroReader{}
+
+	want := "16030301a4010001a003030000000000000000000000000000000000000000000000000000000000000000200000000000000000000000000000000000000000000000000000000000000000008a001600330067c09ec0a2009e0039006bc09fc0a3009f004500be008800c4009ac008c009c023c0acc0aec02bc00ac024c0adc0afc02cc072c073cca913021301cc14c007c012c013c027c02fc014c028c030c060c061c076c077cca8130513041303cc13c011000a002f003cc09cc0a0009c0035003dc09dc0a1009d004100ba008400c0000700040005010000cd00000010000e00000b6578616d706c652e636f6d001700000001000101ff01000100000a000a0008001d001700180019000b00020100002300000010003c003a0268710368326302683206737064792f3306737064792f3206737064792f3108687474702f312e3108687474702f312e3008687474702f302e39000d00140012040308040401050308050501080606010201003300260024001d00200000000000000000000000000000000000000000000000000000000000000000002d00020101002b000706030303020301"
+	assert.Equal(t, want, hex.EncodeToString(buildClientHello("example.com", probes[0])))
+
+	// 10个探测报文的长度与SHA256, 覆盖各种加密套件顺序、GREASE、罕见ALPN与版本支持扩展
+	digests := []struct {
+		length int
+		sha256 string
+	}{
+		{425, "4df71b53da4388d4a369ae62441e2450d98bbfb105db6b2b247a5ff9091336de"},
+		{425, "cdb80aba92f9f2a6f825f6ab1239b080ef2a4f89cb3950ef60c3987b30198e86"},
+		{346, "f29ab516c4d42f0c9da0f71c357fdb3ffacac99e285679a6a174f43ccc2951ea"},
+		{332, "342cab33eaecb69246b562ea255b77ed0b6779116ae85b758de612bcb526018f"},
+		{413, "7a95b118ba769558f2dd4485ef6f9dfc5ae9521436d01af016ccea461407c795"},
+		{414, "2d530cdc8920f632ed1b9e34fab5d2c82f92e268fbd573e24565b1610fb2b966"},
+		{427, "c25f22e2b73e9e11c6fabeb2f783a8e1affd522f964d88a536d7ce0ea952dcd1"},
+		{427, "5f2b0234c937a70938f138c9fd2b6a170b301d23e87b5c1c360b44bf7a66d72a"},
+		{417, "9ecc26c3430bdb437861fe17b555e4a7bcda4fdf4ce15570f544b6053b03d211"},
+		{440, "5df5aeb73e18996595fd881c4b2a0734c8a87e2e8090b60d1bb093780518a9b4"},
+	}
+	for i, p := range probes {
+		hello := buildClientHello("example.com", p)
+		sum := sha256.Sum256(hello)
+		assert.Equal(t, digests[i].length, len(hello), "probe %d", i)
+		assert.Equal(t, digests[i].sha256, hex.EncodeToString(sum[:]), "probe %d", i)
+	}
+}
+
+func TestBuildClientHelloNoSNI(t *testing.T) {
+	defer func(r io.Reader) { randReader = r }(randReader)
+	randReader = zeroReader{}
+
+	// 省略server_name扩展: 类型、长度与列表头共9字节加主机名
+	withSNI := buildClientHello("example.com", probes[0])
+	hello := buildClientHello("", probes[0])
+	assert.Len(t, hello, len(withSNI)-9-len("example.com"))
+	assert.NotContains(t, hex.EncodeToString(hello), "0000000e00000b")
+}
+
+func TestParseServerHello(t *testing.T) {
+	decode := func(s string) []byte {
+		b, err := hex.DecodeString(s)
+		assert.NoError(t, err)
+		return b
+	}
+
+	tests := []struct {
+		hello string
+		want  string
+	}{
+		// TLS 1.2, 32字节会话ID, 携带ALPN
+		{"1603030064020000600303000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f201111111111111111111111111111111111111111111111111111111111111111c02f000018ff01000100001000050003026832000b0002010000170000", "c02f|0303|h2|ff01-0010-000b-0017"},
+		// TLS 1.3, 空会话ID
+		{"160303005a020000560303000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f00130100002e002b0002030400330024001d00202222222222222222222222222222222222222222222222222222222222222222", "1301|0303||002b-0033"},
+		// 告警与截断的报文
+		{"15030300020228", "|||"},
+		{"160303", "|||"},
+		{"", "|||"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, parseServerHello(decode(tt.hello)), tt.hello)
+	}
+}
+
+func TestHashRaw(t *testing.T) {
+	tls12 := "c02f|0303|h2|ff01-0010-000b-0017"
+	tls13 := "1301|0303||002b-0033"
+	raw := strings.Join([]string{tls12, tls13, "|||", tls12, "|||", "|||", tls13, tls13, "|||", tls12}, ",")
+	assert.Equal(t, "29d41d00029d00000041d41d00029d2556854e5125c484971f882a4618a449", hashRaw(raw))
+	assert.Equal(t, "02c000000000000000000000000000e3b0c44298fc1c149afbf4c8996fb924", hashRaw("0005|0302||,|||,|||,|||,|||,|||,|||,|||,|||,|||"))
+
+	// 全部探测无响应
+	empty := hashRaw(strings.Repeat("|||,", 9) + "|||")
+	assert.Equal(t, emptyHash, empty)
+	assert.Len(t, empty, 62)
+}
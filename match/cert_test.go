@@ -0,0 +1,56 @@
+package match
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/enenisme/definger/logger"
+	"github.com/enenisme/definger/pkg"
+)
+
+func TestCertParts(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	p := &pkg.Probes{Options: &pkg.HTTPOptions{}}
+	resp, err := p.HttpRequest(server.URL, pkg.Probe{Data: "GET / HTTP/1.1\r\n\r\n", Timeout: 5})
+	require.NoError(t, err)
+
+	// httptest的证书颁发给Acme Co, 备用名称包含example.com与回环地址
+	leaf := server.Certificate()
+	sum := sha256.Sum256(leaf.Raw)
+	info := resp.CertInfo()
+	require.NotNil(t, info)
+	assert.Contains(t, info.Subject, "O=Acme Co")
+	assert.Equal(t, leaf.Issuer.String(), info.Issuer)
+	assert.Contains(t, info.SANs, "example.com")
+	assert.Contains(t, info.SANs, "127.0.0.1")
+	assert.Equal(t, strings.ToLower(leaf.SerialNumber.Text(16)), info.Serial)
+	assert.Equal(t, hex.EncodeToString(sum[:]), info.SHA256)
+
+	parts := buildParts(resp)
+	assert.Equal(t, info.Subject, parts["cert.subject"])
+	assert.Equal(t, info.Issuer, parts["cert.issuer"])
+	assert.Equal(t, strings.Join(info.SANs, "\n"), parts["cert.san"])
+	assert.Equal(t, info.Serial, parts["cert.serial"])
+	assert.Equal(t, info.SHA256, parts["cert.sha256"])
+
+	// 证书部位可以直接用于匹配
+	tags := []pkg.Tag{
+		{ID: "acme", Info: pkg.Infos{Name: "Acme"}, HTTP: []pkg.HTTP{{Matchers: []pkg.Matchers{{Type: "word", Part: "cert.san", Words: []string{"example\\.com"}}}}}},
+		{ID: "pinned", Info: pkg.Infos{Name: "Pinned"}, HTTP: []pkg.HTTP{{Matchers: []pkg.Matchers{{Type: "word", Part: "cert.sha256", Words: []string{info.SHA256}}}}}},
+		{ID: "other", Info: pkg.Infos{Name: "Other"}, HTTP: []pkg.HTTP{{Matchers: []pkg.Matchers{{Type: "word", Part: "cert.issuer", Words: []string{"Let's Encrypt"}}}}}},
+	}
+	matched, err := Match(resp, pkg.NewRuleSet(tags), "", &logger.Logger{Level: logger.LogLevelError})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Acme", "Pinned"}, matched)
+}
@@ -3,215 +3,172 @@ package match
 import (
 	"crypto/md5"
 	"fmt"
-	"regexp"
-	"sort"
 	"strings"
-	"sync"
-	"sync/atomic"
-	"time"
 
 	"github.com/enenisme/definger/logger"
 	"github.com/enenisme/definger/pkg"
-	"github.com/enenisme/definger/utils"
 )
 
-// 添加包级别的正则表达式缓存
-var (
-	regexpCache sync.Map
-	// 缓存大小限制 (例如限制2500个正则表达式)
-	maxCacheSize = 3000
-	// 当前缓存数量
-	currentCacheSize atomic.Int32
-)
-
-// cacheItem 缓存项
-type cacheItem struct {
-	pattern string
-	regexp  *regexp.Regexp
-	lastUse int64
-}
-
-// cleanupCache 清理最近最少使用的缓存项
-func cleanupCache() {
-	if currentCacheSize.Load() < int32(maxCacheSize) {
-		return
+// Match 匹配探针结果和指纹
+// 参数:
+//   - httpResponse: 探针响应
+//   - rules: 编译后的指纹规则
+//   - favicon: favicon哈希, 为空时favicon匹配器不命中
+//
+// 返回值:
+//   - []string: 匹配到的指纹
+//   - error: 错误信息
+func Match(httpResponse *pkg.HttpResponse, rules *pkg.RuleSet, favicon string, logger *logger.Logger) ([]string, error) {
+	lookup, err := responseContent(httpResponse, rules, favicon, logger)
+	if err != nil {
+		return nil, err
 	}
 
-	var items []cacheItem
-	regexpCache.Range(func(key, value interface{}) bool {
-		items = append(items, cacheItem{
-			pattern: key.(string),
-			regexp:  value.(*regexp.Regexp),
-			lastUse: time.Now().UnixNano(),
-		})
-		return true
-	})
-
-	// 按最后使用时间排序
-	sort.Slice(items, func(i, j int) bool {
-		return items[i].lastUse < items[j].lastUse
-	})
+	// 通过规则索引筛选候选指纹, 只对候选指纹执行完整匹配
+	matchedTags := rules.Match(lookup, make([]string, 0))
 
-	// 删除最老的 20% 缓存项
-	deleteCount := len(items) / 5
-	for i := 0; i < deleteCount; i++ {
-		regexpCache.Delete(items[i].pattern)
-		currentCacheSize.Add(-1)
-	}
+	return matchedTags, nil
 }
 
-// getOrCreateRegexp 获取或创建正则表达式
+// Detect 匹配探针结果和指纹, 同时计算各指纹的置信度
 // 参数:
-//   - pattern: 正则表达式字符串
+//   - httpResponse: 探针响应
+//   - rules: 编译后的指纹规则
+//   - favicon: favicon哈希, 为空时favicon匹配器不命中
 //
 // 返回值:
-//   - *regexp.Regexp: 正则表达式对象
+//   - []pkg.Detection: 匹配到的指纹及其置信度
 //   - error: 错误信息
-func getOrCreateRegexp(pattern string) (*regexp.Regexp, error) {
-	pattern = strings.ToLower(pattern)
-
-	// 检查缓存
-	if cached, ok := regexpCache.Load(pattern); ok {
-		return cached.(*regexp.Regexp), nil
-	}
-
-	// 编译新的正则表达式
-	compiled, err := regexp.Compile(pattern)
+func Detect(httpResponse *pkg.HttpResponse, rules *pkg.RuleSet, favicon string, logger *logger.Logger) ([]pkg.Detection, error) {
+	lookup, err := responseContent(httpResponse, rules, favicon, logger)
 	if err != nil {
-		return nil, fmt.Errorf("编译正则表达式失败: %w", err)
+		return nil, err
 	}
-
-	cleanupCache()
-
-	regexpCache.Store(pattern, compiled)
-	currentCacheSize.Add(1)
-
-	return compiled, nil
+	return rules.Detect(lookup, make([]pkg.Detection, 0)), nil
 }
 
-// Match 匹配探针结果和指纹
+// responseContent 校验参数并生成获取响应部位内容的函数
 // 参数:
 //   - httpResponse: 探针响应
-//   - tags: 指纹
+//   - rules: 编译后的指纹规则
+//   - favicon: favicon哈希
 //
 // 返回值:
-//   - []string: 匹配到的指纹
+//   - pkg.ContentFunc: 部位内容获取函数
 //   - error: 错误信息
-func Match(httpResponse *pkg.HttpResponse, tags *pkg.Tags, favicon string, logger *logger.Logger) ([]string, error) {
-	if httpResponse == nil || tags == nil {
-		return nil, fmt.Errorf("httpResponse或tags为空")
+func responseContent(httpResponse *pkg.HttpResponse, rules *pkg.RuleSet, favicon string, logger *logger.Logger) (pkg.ContentFunc, error) {
+	if httpResponse == nil || rules == nil {
+		return nil, fmt.Errorf("httpResponse或rules为空")
 	}
 
-	// 限制处理的响应体大小为10MB
-	const maxBodySize = 10 * 1024 * 1024
-	if len(httpResponse.Body) > maxBodySize {
-		httpResponse.Body = httpResponse.Body[:maxBodySize]
+	parts := buildParts(httpResponse)
+	if favicon != "" {
+		parts["favicon"] = favicon
 	}
 
-	headerStr := buildHeaderResponse(httpResponse)
-	bodyStr := string(httpResponse.Body)
-	matchedTags := make([]string, 0)
-
-	logger.DebugResponsef("HTTP Response Header: %s", headerStr)
-	logger.DebugResponsef("HTTP Response Body: %s", bodyStr)
+	logger.DebugResponsef("HTTP Response Header: %s", parts["header"])
+	logger.DebugResponsef("HTTP Response Body: %s", parts["body"])
 
-	for _, tag := range tags.Tags {
-		matches := 0
-		totalMatchers := 0
+	return partContent(httpResponse, parts), nil
+}
 
-		for _, http := range tag.HTTP {
-			totalMatchers += len(http.Matchers)
-			for _, matcher := range http.Matchers {
-				innerMatches := 0
-				switch {
-				case matcher.Type == "word" && matcher.Part == "header":
-					for _, word := range matcher.Words {
-						if matchMode(headerStr, word, matcher.Condition) {
-							if matcher.Condition == "or" || matcher.Condition == "" {
-								matchedTags = append(matchedTags, tag.Info.Name)
-							}
-							if matcher.Condition == "and" {
-								innerMatches++
-							}
-						}
-					}
-					// body内and模式
-					if innerMatches == len(matcher.Words) {
-						if http.Mode == "and" {
-							matches++
-						}
-						if http.Mode == "or" || http.Mode == "" {
-							matchedTags = append(matchedTags, tag.Info.Name)
-						}
-					}
-				case matcher.Type == "word" && matcher.Part == "body":
-					for _, word := range matcher.Words {
-						if matchMode(bodyStr, word, matcher.Condition) {
-							if matcher.Condition == "or" || matcher.Condition == "" {
-								matchedTags = append(matchedTags, tag.Info.Name)
-							}
-							if matcher.Condition == "and" {
-								innerMatches++
-							}
-						}
-					}
-					// body内and模式
-					if innerMatches == len(matcher.Words) {
-						if http.Mode == "and" {
-							matches++
-						}
-						if http.Mode == "or" || http.Mode == "" {
-							matchedTags = append(matchedTags, tag.Info.Name)
-						}
-					}
-				}
+// partContent 生成获取响应部位内容的函数
+// 参数:
+//   - httpResponse: 探针响应
+//   - parts: 最终响应的部位内容
+//
+// 返回值:
+//   - pkg.ContentFunc: 部位内容获取函数
+func partContent(httpResponse *pkg.HttpResponse, parts map[string]string) pkg.ContentFunc {
+	// 服务端跳转中间响应的各部分, 仅在匹配器开启hops时构建
+	var hopParts []map[string]string
+	// 标题需要解码响应体后提取, 仅在规则用到title部位时构建
+	var titled, hopsTitled bool
+	return func(part string, hops bool) (string, bool) {
+		if part == "title" && !titled {
+			titlePart(parts)
+			titled = true
+		}
+		content, ok := parts[part]
+		if !hops || len(httpResponse.Hops) == 0 {
+			return content, ok
+		}
+		if hopParts == nil {
+			hopParts = make([]map[string]string, 0, len(httpResponse.Hops))
+			for _, hop := range httpResponse.Hops {
+				hopParts = append(hopParts, buildParts(hop))
 			}
 		}
-		if matches >= totalMatchers {
-			if matches == 0 {
-				continue
+		if part == "title" && !hopsTitled {
+			for _, hop := range hopParts {
+				titlePart(hop)
 			}
-			matchedTags = append(matchedTags, tag.Info.Name)
+			hopsTitled = true
 		}
+		return chainContent(content, ok, hopParts, part)
 	}
+}
 
-	return matchedTags, nil
+// titlePart 从已解码的响应体中提取标题并写入部位内容, 无标题时不写入
+// 参数:
+//   - parts: 该响应的部位内容
+func titlePart(parts map[string]string) {
+	if title, ok := parseTitle(parts["body"]); ok {
+		parts["title"] = title
+	}
 }
 
-// matchPattern 通用的正则匹配函数
+// buildParts 构建匹配器可用的各部分内容, 标题由partContent按需补充
+// 响应体按字符集解码为UTF-8, GBK/Big5等页面可以直接使用中文关键字匹配
 // 参数:
-//   - targetStr: 目标字符串
-//   - patterns: 正则表达式列表
+//   - httpResponse: 探针响应
 //
 // 返回值:
-//   - bool: 是否匹配到
-func matchPattern(targetStr string, patterns []string) bool {
-	for _, pattern := range patterns {
-		if re, err := getOrCreateRegexp(pattern); err == nil && re.MatchString(targetStr) {
-			return true
-		}
+//   - map[string]string: 匹配部位到内容的映射
+func buildParts(httpResponse *pkg.HttpResponse) map[string]string {
+	parts := map[string]string{
+		"header": buildHeaderResponse(httpResponse),
+		"body":   DecodeBody(httpResponse.Body, httpResponse.Header.Get("Content-Type")),
+	}
+	// TLS证书相关部位, 仅HTTPS响应存在
+	if cert := httpResponse.CertInfo(); cert != nil {
+		parts["cert.subject"] = cert.Subject
+		parts["cert.issuer"] = cert.Issuer
+		parts["cert.san"] = strings.Join(cert.SANs, "\n")
+		parts["cert.serial"] = cert.Serial
+		parts["cert.sha256"] = cert.SHA256
 	}
-	return false
+	if httpResponse.JARM != "" {
+		parts["tls.jarm"] = httpResponse.JARM
+	}
+	return parts
 }
 
-// matchMode 处理匹配模式
+// chainContent 拼接最终响应与各跳转响应中同一部位的内容
 // 参数:
-//   - content: 目标字符串
-//   - word: 匹配字段
-//   - mode: 匹配模式
+//   - content: 最终响应的内容
+//   - ok: 最终响应是否存在该部位
+//   - hopParts: 各跳转响应的部位内容
+//   - part: 匹配部位
 //
 // 返回值:
-//   - bool: 是否匹配到
-func matchMode(content, word, mode string) bool {
-	// 处理OR模式
-	if mode == "or" || mode == "" {
-		return matchPattern(content, []string{"(?i)(" + word + ")"})
-	}
-	// 处理AND模式
-	if mode == "and" {
-		return matchPattern(content, []string{"(?i)(" + word + ")"})
+//   - string: 拼接后的内容
+//   - bool: 是否存在该部位
+func chainContent(content string, ok bool, hopParts []map[string]string, part string) (string, bool) {
+	var builder strings.Builder
+	builder.WriteString(content)
+	for _, parts := range hopParts {
+		hopContent, hopOk := parts[part]
+		if !hopOk {
+			continue
+		}
+		if builder.Len() > 0 {
+			builder.WriteString("\n")
+		}
+		builder.WriteString(hopContent)
+		ok = true
 	}
-	return false
+	return builder.String(), ok
 }
 
 // buildHeaderResponse 构建HTTP响应头字符串
@@ -233,43 +190,16 @@ func buildHeaderResponse(httpResponse *pkg.HttpResponse) string {
 	return headerBuilder.String()
 }
 
-// MathTitle 获取title
+// MatchFavicon 计算favicon响应的哈希
 // 参数:
-//   - probes: 探针
-//   - url: 目标URL
+//   - resp: favicon响应
 //
 // 返回值:
-//   - string: title
+//   - string: favicon_hash, 依次为MD5与mmh3哈希, 以换行分隔, 供favicon匹配器使用
 //   - error: 错误信息
-func MathTitle(probes *pkg.Probes, url string) (string, error) {
-	probe := utils.ProbesContent2ProbesStruct(utils.ProbesForGetTitle)
-	resp, err := probes.HttpRequest(url, probe.Probes["093561eda8a835f5a01738826c77dbf6"])
-	if err != nil {
-		return "", fmt.Errorf("获取页面内容失败: %w", err)
-	}
-
-	titleRegex := regexp.MustCompile(`<title>(.*?)</title>`)
-	matches := titleRegex.FindStringSubmatch(string(resp.Body))
-
-	if len(matches) > 1 {
-		return matches[1], nil
-	}
-	return "", fmt.Errorf("未匹配到title")
-}
-
-// MatchFavicon 获取favicon
-// 参数:
-//   - probes: 探针
-//   - url: 目标URL
-//
-// 返回值:
-//   - string: favicon_hash
-//   - error: 错误信息
-func MatchFavicon(probes *pkg.Probes, url string) (string, error) {
-	probe := utils.ProbesContent2ProbesStruct(utils.ProbesForGetFavicon)
-	resp, err := probes.HttpRequest(url, probe.Probes["favicon"])
-	if err != nil {
-		return "", fmt.Errorf("获取页面内容失败: %w", err)
+func MatchFavicon(resp *pkg.HttpResponse) (string, error) {
+	if resp == nil || len(resp.Body) == 0 {
+		return "", fmt.Errorf("favicon响应为空")
 	}
-	return fmt.Sprintf("%x", md5.Sum(resp.Body)), nil
+	return fmt.Sprintf("%x\n%s", md5.Sum(resp.Body), FaviconMMH3(resp.Body)), nil
 }
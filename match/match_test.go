@@ -0,0 +1,256 @@
+package match
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/enenisme/definger/logger"
+	"github.com/enenisme/definger/pkg"
+)
+
+// benchmarkTags 生成指定数量的指纹规则, 大部分为字面量关键字, 少量为正则
+func benchmarkTags(n int) []pkg.Tag {
+	tags := make([]pkg.Tag, 0, n)
+	for i := 0; i < n; i++ {
+		matcher := pkg.Matchers{Type: "word", Part: "body", Words: []string{fmt.Sprintf("product-%05d", i)}}
+		switch i % 10 {
+		case 0:
+			matcher.Part = "header"
+			matcher.Words = []string{fmt.Sprintf("x-powered-by: server%05d", i)}
+		case 1:
+			matcher.Words = []string{fmt.Sprintf(`build-%05d-v\d+`, i)}
+		case 2:
+			matcher.Condition = "and"
+			matcher.Words = []string{fmt.Sprintf("vendor-%05d", i), "copyright"}
+		}
+		tags = append(tags, pkg.Tag{
+			ID:   fmt.Sprintf("rule-%05d", i),
+			Info: pkg.Infos{Name: fmt.Sprintf("Product%05d", i)},
+			HTTP: []pkg.HTTP{{Method: "GET", Path: []string{"/"}, Matchers: []pkg.Matchers{matcher}}},
+		})
+	}
+	return tags
+}
+
+// benchmarkResponse 生成约64KB的响应, 命中其中几条规则
+func benchmarkResponse() *pkg.HttpResponse {
+	var body strings.Builder
+	body.WriteString("<html><head><title>Portal</title></head><body>")
+	for body.Len() < 64*1024 {
+		body.WriteString("<div class=\"row\">lorem ipsum dolor sit amet, consectetur adipiscing elit</div>\n")
+	}
+	body.WriteString("PRODUCT-00043 Vendor-00072 build-00081-v12 copyright</body></html>")
+
+	header := http.Header{}
+	header.Set("Server", "nginx")
+	header.Set("X-Powered-By", "Server00030")
+	return &pkg.HttpResponse{StatusCode: 200, Header: header, Body: []byte(body.String())}
+}
+
+// matchLinear 逐条指纹、逐个关键字即时编译正则匹配, 作为未建索引时的对照实现
+func matchLinear(resp *pkg.HttpResponse, tags []pkg.Tag) []string {
+	lookup := partContent(resp, buildParts(resp))
+	matched := make([]string, 0)
+	for _, tag := range tags {
+		hit := false
+		for _, block := range tag.HTTP {
+			matches := 0
+			for _, matcher := range block.Matchers {
+				content, ok := lookup(matcher.Part, false)
+				if matcher.Type != "word" || !ok {
+					continue
+				}
+				matcherHit := matcher.Condition == "and" || len(matcher.Words) == 0
+				for _, word := range matcher.Words {
+					ok, _ := regexp.MatchString("(?i)("+word+")", content)
+					if matcher.Condition == "and" && !ok {
+						matcherHit = false
+						break
+					}
+					if matcher.Condition != "and" && ok {
+						matcherHit = true
+						break
+					}
+				}
+				if matcherHit != matcher.Negative {
+					matches++
+				}
+			}
+			// 各HTTP块独立判断, and块要求块内全部匹配器成立
+			if block.Mode == "and" {
+				hit = hit || (matches == len(block.Matchers) && matches > 0)
+			} else {
+				hit = hit || matches > 0
+			}
+		}
+		if hit {
+			matched = append(matched, tag.Info.Name)
+		}
+	}
+	return matched
+}
+
+func TestMatchIndexMatchesLinear(t *testing.T) {
+	tags := benchmarkTags(1000)
+	resp := benchmarkResponse()
+	log := &logger.Logger{Level: logger.LogLevelError}
+
+	got, err := Match(resp, pkg.NewRuleSet(tags), "", log)
+	assert.NoError(t, err)
+	assert.Equal(t, matchLinear(resp, tags), got)
+	assert.ElementsMatch(t, []string{"Product00030", "Product00043", "Product00072", "Product00081"}, got)
+}
+
+func TestMatchModes(t *testing.T) {
+	// (acme|globex) && portal && !legacy
+	tags := []pkg.Tag{{ID: "nested", Info: pkg.Infos{Name: "Nested"}, HTTP: []pkg.HTTP{{Mode: "and", Matchers: []pkg.Matchers{
+		{Type: "word", Part: "body", Words: []string{"acme", "globex"}},
+		{Type: "word", Part: "header", Words: []string{"portal"}},
+		{Type: "word", Part: "body", Words: []string{"legacy"}, Negative: true},
+	}}}}}
+	rules := pkg.NewRuleSet(tags)
+	log := &logger.Logger{Level: logger.LogLevelError}
+
+	for body, want := range map[string]bool{
+		"globex": true,
+		"acme":   true,
+		"other":  false,
+		// 取反匹配器不成立, and块整体不成立
+		"acme legacy": false,
+	} {
+		header := http.Header{}
+		header.Set("X-App", "Portal")
+		resp := &pkg.HttpResponse{StatusCode: 200, Header: header, Body: []byte(body)}
+		got, err := Match(resp, rules, "", log)
+		assert.NoError(t, err)
+		assert.Equal(t, want, len(got) == 1, body)
+		assert.Equal(t, matchLinear(resp, tags), got, body)
+	}
+}
+
+func TestDetectConfidence(t *testing.T) {
+	tags := []pkg.Tag{
+		{ID: "weighted", Info: pkg.Infos{Name: "Weighted"}, Threshold: 1, HTTP: []pkg.HTTP{{Matchers: []pkg.Matchers{
+			{Type: "word", Part: "body", Words: []string{"common.js"}, Weight: 0.3},
+			{Type: "word", Part: "body", Words: []string{"acme-portal"}, Weight: 0.8},
+		}}}},
+		{ID: "plain", Info: pkg.Infos{Name: "Plain"}, HTTP: []pkg.HTTP{{Matchers: []pkg.Matchers{
+			{Type: "word", Part: "body", Words: []string{"common.js"}},
+		}}}},
+	}
+	rules := pkg.NewRuleSet(tags)
+	log := &logger.Logger{Level: logger.LogLevelError}
+
+	for body, want := range map[string][]pkg.Detection{
+		"common.js":             {{Name: "Weighted", Confidence: 0.3}, {Name: "Plain", Confidence: 1}},
+		"acme-portal":           {{Name: "Weighted", Confidence: 0.8}},
+		"common.js acme-portal": {{Name: "Weighted", Confidence: 1}, {Name: "Plain", Confidence: 1}},
+	} {
+		resp := &pkg.HttpResponse{StatusCode: 200, Header: http.Header{}, Body: []byte(body)}
+		got, err := Detect(resp, rules, "", log)
+		assert.NoError(t, err)
+		assert.Equal(t, want, got, body)
+	}
+}
+
+func TestDetectBlocks(t *testing.T) {
+	word := func(words string, weight float64, negative bool) pkg.Matchers {
+		return pkg.Matchers{Type: "word", Part: "body", Words: []string{words}, Weight: weight, Negative: negative}
+	}
+	log := &logger.Logger{Level: logger.LogLevelError}
+
+	for _, tc := range []struct {
+		name   string
+		blocks []pkg.HTTP
+		body   string
+		want   []pkg.Detection
+	}{
+		{
+			// 两个and块中任一块全部成立即命中, 置信度只计成立的块
+			name: "multiple and blocks",
+			blocks: []pkg.HTTP{
+				{Mode: "and", Matchers: []pkg.Matchers{word("alpha", 0.5, false), word("beta", 0.5, false)}},
+				{Mode: "and", Matchers: []pkg.Matchers{word("gamma", 0.5, false), word("delta", 0.5, false)}},
+			},
+			body: "alpha beta gamma",
+			want: []pkg.Detection{{Name: "Rule", Confidence: 1}},
+		},
+		{
+			name: "and block partially matched",
+			blocks: []pkg.HTTP{
+				{Mode: "and", Matchers: []pkg.Matchers{word("alpha", 0.5, false), word("beta", 0.5, false)}},
+			},
+			body: "alpha",
+			want: []pkg.Detection{},
+		},
+		{
+			// or块成立不能使and块的部分匹配计入置信度
+			name: "mixed modes",
+			blocks: []pkg.HTTP{
+				{Matchers: []pkg.Matchers{word("common", 0.2, false)}},
+				{Mode: "and", Matchers: []pkg.Matchers{word("alpha", 0.5, false), word("beta", 0.5, false)}},
+			},
+			body: "common alpha",
+			want: []pkg.Detection{{Name: "Rule", Confidence: 0.2}},
+		},
+		{
+			name: "negative only",
+			blocks: []pkg.HTTP{
+				{Mode: "and", Matchers: []pkg.Matchers{word("alpha", 0.5, false), word("beta", 0.5, false)}},
+				{Mode: "and", Matchers: []pkg.Matchers{word("legacy", 0, true)}},
+			},
+			body: "modern",
+			want: []pkg.Detection{},
+		},
+	} {
+		tags := []pkg.Tag{{ID: "rule", Info: pkg.Infos{Name: "Rule"}, Threshold: 1, HTTP: tc.blocks}}
+		resp := &pkg.HttpResponse{StatusCode: 200, Header: http.Header{}, Body: []byte(tc.body)}
+		got, err := Detect(resp, pkg.NewRuleSet(tags), "", log)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.want, got, tc.name)
+
+		// Match同样按块判断, 与对照实现一致
+		matched, err := Match(resp, pkg.NewRuleSet(tags), "", log)
+		assert.NoError(t, err)
+		assert.Equal(t, matchLinear(resp, tags), matched, tc.name)
+	}
+}
+
+func TestRuleSetMatchNoAllocs(t *testing.T) {
+	rules := pkg.NewRuleSet(benchmarkTags(1000))
+	resp := benchmarkResponse()
+	lookup := partContent(resp, buildParts(resp))
+	dst := make([]string, 0, 16)
+
+	allocs := testing.AllocsPerRun(20, func() {
+		dst = rules.Match(lookup, dst[:0])
+	})
+	assert.Equal(t, float64(0), allocs)
+	assert.Len(t, dst, 4)
+}
+
+func BenchmarkMatchLinear(b *testing.B) {
+	tags := benchmarkTags(10000)
+	resp := benchmarkResponse()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		matchLinear(resp, tags)
+	}
+}
+
+func BenchmarkMatchIndexed(b *testing.B) {
+	rules := pkg.NewRuleSet(benchmarkTags(10000))
+	resp := benchmarkResponse()
+	log := &logger.Logger{Level: logger.LogLevelError}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Match(resp, rules, "", log)
+	}
+}
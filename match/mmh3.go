@@ -0,0 +1,87 @@
+package match
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"math/bits"
+	"strconv"
+)
+
+// FaviconMMH3 计算favicon的mmh3哈希, 与FOFA/Shodan的icon_hash及EHole的faviconhash一致
+// 哈希对象为每76个字符换行的base64编码内容, 结果为有符号32位整数
+// 参数:
+//   - body: favicon内容
+//
+// 返回值:
+//   - string: 十进制哈希值
+func FaviconMMH3(body []byte) string {
+	return strconv.FormatInt(int64(int32(murmur3(base64Lines(body), 0))), 10)
+}
+
+// base64Lines 按MIME格式编码base64, 每76个字符及末尾各追加一个换行
+// 参数:
+//   - data: 原始内容
+//
+// 返回值:
+//   - []byte: 编码结果
+func base64Lines(data []byte) []byte {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	out := make([]byte, 0, len(encoded)+len(encoded)/76+1)
+	for len(encoded) > 76 {
+		out = append(out, encoded[:76]...)
+		out = append(out, '\n')
+		encoded = encoded[76:]
+	}
+	out = append(out, encoded...)
+	return append(out, '\n')
+}
+
+// murmur3 计算32位MurmurHash3
+// 参数:
+//   - data: 原始内容
+//   - seed: 种子
+//
+// 返回值:
+//   - uint32: 哈希值
+func murmur3(data []byte, seed uint32) uint32 {
+	const (
+		c1 = 0xcc9e2d51
+		c2 = 0x1b873593
+	)
+
+	h := seed
+	n := len(data) / 4 * 4
+	for i := 0; i < n; i += 4 {
+		k := binary.LittleEndian.Uint32(data[i:])
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+		h = bits.RotateLeft32(h, 13)
+		h = h*5 + 0xe6546b64
+	}
+
+	var k uint32
+	switch tail := data[n:]; len(tail) {
+	case 3:
+		k ^= uint32(tail[2]) << 16
+		fallthrough
+	case 2:
+		k ^= uint32(tail[1]) << 8
+		fallthrough
+	case 1:
+		k ^= uint32(tail[0])
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+	}
+
+	h ^= uint32(len(data))
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+	return h
+}
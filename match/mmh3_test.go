@@ -0,0 +1,35 @@
+package match
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/enenisme/definger/logger"
+	"github.com/enenisme/definger/pkg"
+)
+
+func TestMurmur3(t *testing.T) {
+	assert.Equal(t, uint32(0), murmur3(nil, 0))
+	assert.Equal(t, uint32(0x514e28b7), murmur3(nil, 1))
+	assert.Equal(t, uint32(0x248bfa47), murmur3([]byte("hello"), 0))
+	assert.Equal(t, uint32(0x2e4ff723), murmur3([]byte("The quick brown fox jumps over the lazy dog"), 0))
+}
+
+func TestMatchFaviconAndTitle(t *testing.T) {
+	icon := &pkg.HttpResponse{StatusCode: 200, Body: []byte("\x00\x00\x01\x00fake-icon")}
+	favicon, err := MatchFavicon(icon)
+	assert.NoError(t, err)
+
+	rules := pkg.NewRuleSet([]pkg.Tag{
+		{ID: "icon", Info: pkg.Infos{Name: "Icon"}, HTTP: []pkg.HTTP{{Matchers: []pkg.Matchers{{Type: "favicon", Hash: []string{FaviconMMH3(icon.Body)}}}}}},
+		{ID: "title", Info: pkg.Infos{Name: "Title"}, HTTP: []pkg.HTTP{{Matchers: []pkg.Matchers{{Type: "word", Part: "title", Words: []string{"^Acme Console$"}}}}}},
+		{ID: "other", Info: pkg.Infos{Name: "Other"}, HTTP: []pkg.HTTP{{Matchers: []pkg.Matchers{{Type: "favicon", Hash: []string{"-1"}}}}}},
+	})
+	resp := &pkg.HttpResponse{StatusCode: 200, Header: http.Header{}, Body: []byte("<title>Acme Console</title>")}
+
+	got, err := Match(resp, rules, favicon, &logger.Logger{Level: logger.LogLevelError})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"Icon", "Title"}, got)
+}
@@ -0,0 +1,90 @@
+package match
+
+import (
+	"strings"
+
+	"github.com/enenisme/definger/logger"
+	"github.com/enenisme/definger/pkg"
+)
+
+// SampleResult 定义单个样例的测试结果
+type SampleResult struct {
+	Sample   string // 样例名称
+	Positive bool   // 是否为正例
+	Passed   bool   // 是否通过
+	Err      error  // 加载样例失败时的错误
+}
+
+// RunSamples 使用规则自带的样例响应测试规则, 不发送网络请求
+// favicon匹配器使用样例指定的favicon哈希或图标文件, 样例未指定时不命中
+// 参数:
+//   - tag: 指纹规则
+//   - baseDir: 解析样例文件相对路径的目录
+//   - logger: 日志对象
+//
+// 返回值:
+//   - []SampleResult: 各样例的测试结果, 规则没有样例时为空
+func RunSamples(tag pkg.Tag, baseDir string, logger *logger.Logger) []SampleResult {
+	if tag.Samples == nil {
+		return nil
+	}
+
+	// 单独编译该规则, 避免同名规则互相干扰
+	rules := pkg.NewRuleSet([]pkg.Tag{tag})
+	var results []SampleResult
+	run := func(samples []pkg.Sample, positive bool) {
+		for i := range samples {
+			result := SampleResult{Sample: samples[i].Label(i), Positive: positive}
+			resp, err := samples[i].Response(baseDir)
+			if err != nil {
+				result.Err = err
+				results = append(results, result)
+				continue
+			}
+			favicon, err := sampleFavicon(&samples[i], baseDir)
+			if err != nil {
+				result.Err = err
+				results = append(results, result)
+				continue
+			}
+
+			matched, err := Match(resp, rules, favicon, logger)
+			if err != nil {
+				result.Err = err
+			} else {
+				result.Passed = (len(matched) > 0) == positive
+			}
+			results = append(results, result)
+		}
+	}
+	run(tag.Samples.Positive, true)
+	run(tag.Samples.Negative, false)
+	return results
+}
+
+// sampleFavicon 获取样例的favicon哈希, 图标文件按扫描时的方式计算MD5与mmh3
+// 参数:
+//   - sample: 样例
+//   - baseDir: 解析图标文件相对路径的目录
+//
+// 返回值:
+//   - string: favicon哈希, 以换行分隔, 样例未指定favicon时为空
+//   - error: 错误信息
+func sampleFavicon(sample *pkg.Sample, baseDir string) (string, error) {
+	hashes := make([]string, 0, 3)
+	if sample.Favicon != "" {
+		hashes = append(hashes, sample.Favicon)
+	}
+	data, err := sample.ReadFavicon(baseDir)
+	if err != nil {
+		return "", err
+	}
+	if len(data) > 0 {
+		hash, err := MatchFavicon(&pkg.HttpResponse{Body: data})
+		if err != nil {
+			return "", err
+		}
+		hashes = append(hashes, hash)
+	}
+	return strings.Join(hashes, "\n"), nil
+}
@@ -0,0 +1,60 @@
+package match
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/enenisme/definger/logger"
+	"github.com/enenisme/definger/pkg"
+)
+
+func TestRunSamples(t *testing.T) {
+	dir := t.TempDir()
+	raw := "HTTP/1.1 200 OK\nServer: LegacyBox/2.1\nContent-Length: 1\n\n<html>Legacy Box</html>\n"
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "legacy.http"), []byte(raw), 0o644))
+
+	tag := pkg.Tag{
+		ID:   "legacy-box",
+		Info: pkg.Infos{Name: "LegacyBox"},
+		HTTP: []pkg.HTTP{{Matchers: []pkg.Matchers{{Type: "word", Part: "body", Words: []string{"legacy box"}}}}},
+		Samples: &pkg.Samples{
+			Positive: []pkg.Sample{{File: "legacy.http"}, {Name: "inline", Body: "<title>Legacy Box</title>"}},
+			Negative: []pkg.Sample{{Name: "other", Body: "<title>Other</title>"}, {Name: "hit", Body: "legacy box"}, {File: "missing.http"}},
+		},
+	}
+
+	results := RunSamples(tag, dir, &logger.Logger{Level: logger.LogLevelError})
+	assert.Len(t, results, 5)
+	assert.True(t, results[0].Passed)
+	assert.True(t, results[1].Passed)
+	assert.True(t, results[2].Passed)
+	assert.False(t, results[3].Passed)
+	assert.Error(t, results[4].Err)
+	assert.Equal(t, "missing.http", results[4].Sample)
+}
+
+func TestRunSamplesFavicon(t *testing.T) {
+	dir := t.TempDir()
+	icon := []byte("\x00\x00\x01\x00fake-icon")
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "favicon.ico"), icon, 0o644))
+
+	tag := pkg.Tag{
+		ID:   "icon-only",
+		Info: pkg.Infos{Name: "IconOnly"},
+		HTTP: []pkg.HTTP{{Matchers: []pkg.Matchers{{Type: "favicon", Hash: []string{FaviconMMH3(icon), "116323821"}}}}},
+		Samples: &pkg.Samples{
+			Positive: []pkg.Sample{{Name: "hash", Favicon: "116323821"}, {Name: "file", FaviconFile: "favicon.ico"}},
+			Negative: []pkg.Sample{{Name: "no-favicon", Body: "<title>Home</title>"}, {Name: "missing", Body: "x", FaviconFile: "missing.ico"}},
+		},
+	}
+
+	results := RunSamples(tag, dir, &logger.Logger{Level: logger.LogLevelError})
+	assert.Len(t, results, 4)
+	assert.True(t, results[0].Passed, "样例的favicon哈希参与匹配")
+	assert.True(t, results[1].Passed, "图标文件按扫描时的方式计算哈希")
+	assert.True(t, results[2].Passed, "未指定favicon时favicon匹配器不命中")
+	assert.Error(t, results[3].Err)
+}
@@ -0,0 +1,151 @@
+package match
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+
+	"github.com/enenisme/definger/pkg"
+)
+
+// sniffEncodings 未声明字符集且非UTF-8时依次尝试的编码, 靠前的优先
+var sniffEncodings = []encoding.Encoding{
+	simplifiedchinese.GB18030,
+	traditionalchinese.Big5,
+	japanese.ShiftJIS,
+}
+
+// MathTitle 从页面响应中提取title
+// 参数:
+//   - resp: 页面响应
+//
+// 返回值:
+//   - string: title
+//   - error: 错误信息
+func MathTitle(resp *pkg.HttpResponse) (string, error) {
+	if resp == nil {
+		return "", fmt.Errorf("页面响应为空")
+	}
+
+	body := DecodeBody(resp.Body, resp.Header.Get("Content-Type"))
+	if title, ok := parseTitle(body); ok {
+		return title, nil
+	}
+	return "", fmt.Errorf("未匹配到title")
+}
+
+// DecodeBody 将响应体解码为UTF-8字符串
+// 依次参考Content-Type字符集、BOM与meta字符集声明, 均未声明时按UTF-8校验并嗅探GBK/Big5/Shift-JIS
+// 参数:
+//   - body: 原始响应体
+//   - contentType: Content-Type响应头
+//
+// 返回值:
+//   - string: UTF-8字符串
+func DecodeBody(body []byte, contentType string) string {
+	if len(body) == 0 {
+		return ""
+	}
+
+	enc, name, certain := charset.DetermineEncoding(body, contentType)
+	if !certain && name == "windows-1252" {
+		// 未声明字符集, DetermineEncoding回退为windows-1252, 此时自行嗅探
+		if utf8.Valid(body) {
+			return string(body)
+		}
+		return sniffDecode(body)
+	}
+	if name == "utf-8" {
+		return string(body)
+	}
+
+	decoded, err := decode(body, enc)
+	if err != nil {
+		return string(body)
+	}
+	return decoded
+}
+
+// sniffDecode 依次尝试候选编码, 选择替换字符最少的结果
+// 参数:
+//   - body: 原始响应体
+//
+// 返回值:
+//   - string: UTF-8字符串
+func sniffDecode(body []byte) string {
+	best, bestBad := string(body), -1
+	for _, enc := range sniffEncodings {
+		decoded, err := decode(body, enc)
+		if err != nil {
+			continue
+		}
+		bad := strings.Count(decoded, string(utf8.RuneError))
+		if bestBad == -1 || bad < bestBad {
+			best, bestBad = decoded, bad
+		}
+		if bad == 0 {
+			break
+		}
+	}
+	return best
+}
+
+// decode 使用指定编码解码
+func decode(body []byte, enc encoding.Encoding) (string, error) {
+	decoded, err := io.ReadAll(enc.NewDecoder().Reader(bytes.NewReader(body)))
+	if err != nil {
+		return "", err
+	}
+	return string(decoded), nil
+}
+
+// parseTitle 使用HTML分词器解析title, 自动处理大小写、属性、实体与多行
+// 参数:
+//   - body: UTF-8页面内容
+//
+// 返回值:
+//   - string: 规范化空白后的title
+//   - bool: 是否找到title
+func parseTitle(body string) (string, bool) {
+	tokenizer := html.NewTokenizer(strings.NewReader(body))
+	inTitle := false
+	var title strings.Builder
+
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			if inTitle {
+				return normalizeSpace(title.String()), true
+			}
+			return "", false
+		case html.StartTagToken:
+			name, _ := tokenizer.TagName()
+			if string(name) == "title" {
+				inTitle = true
+			}
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			if inTitle && string(name) == "title" {
+				return normalizeSpace(title.String()), true
+			}
+		case html.TextToken:
+			if inTitle {
+				title.Write(tokenizer.Text())
+			}
+		}
+	}
+}
+
+// normalizeSpace 合并连续空白并去除首尾空白
+func normalizeSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
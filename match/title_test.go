@@ -0,0 +1,89 @@
+package match
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+
+	"github.com/enenisme/definger/logger"
+	"github.com/enenisme/definger/pkg"
+)
+
+func newTitleResponse(body []byte, contentType string) *pkg.HttpResponse {
+	header := http.Header{}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	return &pkg.HttpResponse{Header: header, Body: body}
+}
+
+func TestMathTitle(t *testing.T) {
+	gbk, _ := simplifiedchinese.GBK.NewEncoder().Bytes([]byte("<html><head><title>用户登录</title></head></html>"))
+	big5, _ := traditionalchinese.Big5.NewEncoder().Bytes([]byte(`<meta charset="big5"><title>系統管理</title>`))
+
+	cases := []struct {
+		name        string
+		body        []byte
+		contentType string
+		want        string
+	}{
+		{"大写标签", []byte("<HTML><TITLE>Admin</TITLE></HTML>"), "", "Admin"},
+		{"标签属性", []byte(`<title id="t" data-x="1">Dashboard</title>`), "", "Dashboard"},
+		{"多行与空白", []byte("<title>\n  Welcome\n\t to   Portal\n</title>"), "", "Welcome to Portal"},
+		{"HTML实体", []byte("<title>A &amp; B &lt;C&gt; &#x4e2d;</title>"), "", "A & B <C> 中"},
+		{"Content-Type字符集", gbk, "text/html; charset=gbk", "用户登录"},
+		{"meta字符集", big5, "text/html", "系統管理"},
+		{"未声明字符集嗅探", gbk, "text/html", "用户登录"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			title, err := MathTitle(newTitleResponse(c.body, c.contentType))
+			assert.NoError(t, err)
+			assert.Equal(t, c.want, title)
+		})
+	}
+
+	_, err := MathTitle(newTitleResponse([]byte("<html>no title</html>"), ""))
+	assert.Error(t, err)
+}
+
+func TestTitlePartLazy(t *testing.T) {
+	hop := newTitleResponse([]byte("<title>Redirecting</title>"), "")
+	resp := newTitleResponse([]byte("<title>Login</title>"), "")
+	resp.Hops = []*pkg.HttpResponse{hop}
+
+	// 标题不随其他部位一起构建
+	parts := buildParts(resp)
+	assert.NotContains(t, parts, "title")
+
+	lookup := partContent(resp, parts)
+	title, ok := lookup("title", false)
+	assert.True(t, ok)
+	assert.Equal(t, "Login", title)
+
+	title, ok = lookup("title", true)
+	assert.True(t, ok)
+	assert.Equal(t, "Login\nRedirecting", title)
+
+	_, ok = partContent(newTitleResponse([]byte("<html></html>"), ""), map[string]string{})("title", false)
+	assert.False(t, ok)
+}
+
+func TestDecodedBodyPart(t *testing.T) {
+	gbk, _ := simplifiedchinese.GBK.NewEncoder().Bytes([]byte("<html><title>用户登录</title><body>版权所有 某某科技</body></html>"))
+	tags := []pkg.Tag{
+		{ID: "gbk", Info: pkg.Infos{Name: "GBK"}, HTTP: []pkg.HTTP{{Matchers: []pkg.Matchers{{Type: "word", Part: "body", Words: []string{"某某科技"}}}}}},
+		{ID: "title", Info: pkg.Infos{Name: "Title"}, HTTP: []pkg.HTTP{{Matchers: []pkg.Matchers{{Type: "word", Part: "title", Words: []string{"^用户登录$"}}}}}},
+	}
+
+	// 响应体关键字匹配解码后的内容, 与标题使用相同的字符集判断
+	for _, contentType := range []string{"text/html; charset=gbk", "text/html"} {
+		matched, err := Match(newTitleResponse(gbk, contentType), pkg.NewRuleSet(tags), "", &logger.Logger{Level: logger.LogLevelError})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"GBK", "Title"}, matched, contentType)
+	}
+}
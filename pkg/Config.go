@@ -1,6 +1,8 @@
 package pkg
 
 type Config struct {
-	Tags   *Tags
-	Probes *Probes
+	Tags     *Tags
+	Rules    *RuleSet // 加载时编译的指纹规则
+	Probes   *Probes
+	Warnings []Issue // 加载规则时产生的警告, 例如nuclei模板中不支持的功能
 }
@@ -0,0 +1,119 @@
+package pkg
+
+// acNode 定义Aho-Corasick自动机的节点
+type acNode struct {
+	next map[byte]int32 // 子节点
+	fail int32          // 失配指针
+	dict int32          // 沿失配链最近的输出节点, -1表示不存在
+	out  []int32        // 在该节点结束的模式串ID
+}
+
+// AhoCorasick 定义多模式串匹配自动机, 匹配时忽略ASCII大小写
+type AhoCorasick struct {
+	nodes []acNode
+	root  [256]int32 // 根节点的完整转移表, 减少根节点处的map查找
+}
+
+// NewAhoCorasick 构建Aho-Corasick自动机
+// 参数:
+//   - patterns: 模式串, 下标即模式串ID
+//
+// 返回:
+//   - *AhoCorasick: 自动机
+func NewAhoCorasick(patterns []string) *AhoCorasick {
+	ac := &AhoCorasick{nodes: []acNode{{dict: -1}}}
+
+	// 构建字典树
+	for id, pattern := range patterns {
+		state := int32(0)
+		for i := 0; i < len(pattern); i++ {
+			c := lowerASCII(pattern[i])
+			node := &ac.nodes[state]
+			if node.next == nil {
+				node.next = make(map[byte]int32)
+			}
+			child, ok := node.next[c]
+			if !ok {
+				child = int32(len(ac.nodes))
+				node.next[c] = child
+				ac.nodes = append(ac.nodes, acNode{dict: -1})
+			}
+			state = child
+		}
+		ac.nodes[state].out = append(ac.nodes[state].out, int32(id))
+	}
+
+	// 广度优先计算失配指针
+	queue := make([]int32, 0, len(ac.nodes))
+	for c, child := range ac.nodes[0].next {
+		ac.root[c] = child
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		for c, child := range ac.nodes[state].next {
+			fail := ac.nodes[state].fail
+			for fail != 0 {
+				if next, ok := ac.nodes[fail].next[c]; ok {
+					fail = next
+					break
+				}
+				fail = ac.nodes[fail].fail
+			}
+			if fail == 0 {
+				fail = ac.root[c]
+				if fail == child {
+					fail = 0
+				}
+			}
+			ac.nodes[child].fail = fail
+			if len(ac.nodes[fail].out) > 0 {
+				ac.nodes[child].dict = fail
+			} else {
+				ac.nodes[child].dict = ac.nodes[fail].dict
+			}
+			queue = append(queue, child)
+		}
+	}
+	return ac
+}
+
+// Scan 扫描文本, 对每次命中的模式串调用fn
+// 参数:
+//   - text: 待扫描文本
+//   - fn: 命中回调, 参数为模式串ID
+func (ac *AhoCorasick) Scan(text string, fn func(id int)) {
+	if ac == nil {
+		return
+	}
+	state := int32(0)
+	for i := 0; i < len(text); i++ {
+		c := lowerASCII(text[i])
+		for {
+			if state == 0 {
+				state = ac.root[c]
+				break
+			}
+			if next, ok := ac.nodes[state].next[c]; ok {
+				state = next
+				break
+			}
+			state = ac.nodes[state].fail
+		}
+
+		for out := state; out > 0; out = ac.nodes[out].dict {
+			for _, id := range ac.nodes[out].out {
+				fn(int(id))
+			}
+		}
+	}
+}
+
+// lowerASCII 将ASCII大写字母转换为小写
+func lowerASCII(c byte) byte {
+	if 'A' <= c && c <= 'Z' {
+		return c + ('a' - 'A')
+	}
+	return c
+}
@@ -0,0 +1,221 @@
+package pkg
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/enenisme/definger/logger"
+)
+
+// DefaultMaxBodySize 默认的响应体读取上限(10MB)
+const DefaultMaxBodySize = 10 * 1024 * 1024
+
+// decodedEncodingHeader 暂存已解压响应的原始Content-Encoding, 避免resty再次按gzip解码,
+// 生成HttpResponse时由restoreEncoding还原, 规则仍可匹配原始响应头
+const decodedEncodingHeader = "X-Definger-Decoded-Encoding"
+
+// bodyTransport 定义解压并限制响应体大小的Transport
+type bodyTransport struct {
+	base    http.RoundTripper
+	maxSize int64
+	log     *logger.Logger // 为空时不记录截断
+}
+
+// RoundTrip 实现http.RoundTripper, 按Content-Encoding解压响应体并限制读取大小
+// 参数:
+//   - req: HTTP请求
+//
+// 返回:
+//   - *http.Response: 响应体已替换为解压后的流
+//   - error: 错误信息
+func (t *bodyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.Body == nil || resp.Body == http.NoBody {
+		return resp, err
+	}
+
+	body, err := decodeBody(resp.Body, resp.Header.Get("Content-Encoding"))
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	if body != resp.Body {
+		// 已解压, 避免上层再次按gzip解码, 原始编码暂存后还原
+		resp.Header.Set(decodedEncodingHeader, resp.Header.Get("Content-Encoding"))
+		resp.Header.Del("Content-Encoding")
+		resp.Header.Del("Content-Length")
+		resp.ContentLength = -1
+		resp.Uncompressed = true
+	}
+
+	// 超出上限的部分不读取, 连接随Close一起关闭
+	limited := &limitedBody{reader: body, remaining: t.maxSize, closer: body}
+	if t.log != nil {
+		url, maxSize := req.URL.String(), t.maxSize
+		limited.onTruncate = func() {
+			t.log.Debugf("响应体超过读取上限%d字节, 已截断: %s", maxSize, url)
+		}
+	}
+	resp.Body = limited
+	return resp, nil
+}
+
+// restoreEncoding 还原bodyTransport解压时暂存的Content-Encoding响应头
+// 参数:
+//   - header: 响应头, 原地修改
+//
+// 返回:
+//   - http.Header: 还原后的响应头
+func restoreEncoding(header http.Header) http.Header {
+	if encoding := header.Get(decodedEncodingHeader); encoding != "" {
+		header.Set("Content-Encoding", encoding)
+		header.Del(decodedEncodingHeader)
+	}
+	return header
+}
+
+// limitedBody 定义限制读取大小的响应体
+type limitedBody struct {
+	reader     io.Reader
+	remaining  int64
+	closer     io.Closer
+	onTruncate func() // 读到上限且仍有剩余数据时调用一次
+}
+
+// Read 读取响应体, 超过上限后返回io.EOF
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		if b.onTruncate != nil {
+			var next [1]byte
+			if n, _ := io.ReadFull(b.reader, next[:]); n > 0 {
+				b.onTruncate()
+			}
+			b.onTruncate = nil
+		}
+		return 0, io.EOF
+	}
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err := b.reader.Read(p)
+	b.remaining -= int64(n)
+	return n, err
+}
+
+// Close 关闭底层响应体
+func (b *limitedBody) Close() error {
+	return b.closer.Close()
+}
+
+// decodedBody 定义解压后的响应体, 关闭时同时关闭解压器与原始响应体
+type decodedBody struct {
+	io.Reader
+	closers []io.Closer
+}
+
+// Close 依次关闭解压器与原始响应体
+func (b *decodedBody) Close() error {
+	var err error
+	for _, c := range b.closers {
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// decodeBody 按Content-Encoding解压响应体, 支持gzip/deflate/br/zstd及多重编码
+// 参数:
+//   - body: 原始响应体
+//   - encoding: Content-Encoding响应头
+//
+// 返回:
+//   - io.ReadCloser: 解压后的响应体, 无需解压时原样返回
+//   - error: 错误信息
+func decodeBody(body io.ReadCloser, encoding string) (io.ReadCloser, error) {
+	if strings.TrimSpace(encoding) == "" {
+		return body, nil
+	}
+
+	// 多重编码按逆序解压
+	encodings := strings.Split(encoding, ",")
+	reader := io.Reader(body)
+	closers := []io.Closer{}
+	for i := len(encodings) - 1; i >= 0; i-- {
+		var err error
+		switch strings.ToLower(strings.TrimSpace(encodings[i])) {
+		case "", "identity":
+			continue
+		case "gzip", "x-gzip":
+			var gz *gzip.Reader
+			if gz, err = gzip.NewReader(reader); err == nil {
+				reader = gz
+				closers = append(closers, gz)
+			}
+		case "deflate":
+			reader, err = newDeflateReader(reader)
+		case "br":
+			reader = brotli.NewReader(reader)
+		case "zstd":
+			var zr *zstd.Decoder
+			if zr, err = zstd.NewReader(reader, zstd.WithDecoderConcurrency(1)); err == nil {
+				reader = zr
+				closers = append(closers, zstdCloser{zr})
+			}
+		default:
+			// 未知编码保留原始内容
+			for _, c := range closers {
+				c.Close()
+			}
+			return body, nil
+		}
+		if err != nil {
+			for _, c := range closers {
+				c.Close()
+			}
+			return nil, fmt.Errorf("解压响应体失败(%s): %v", encodings[i], err)
+		}
+	}
+
+	return &decodedBody{Reader: reader, closers: append(closers, body)}, nil
+}
+
+// newDeflateReader 创建deflate解压器, 兼容zlib封装与裸deflate两种格式
+// 参数:
+//   - r: 压缩数据
+//
+// 返回:
+//   - io.Reader: 解压后的数据
+//   - error: 错误信息
+func newDeflateReader(r io.Reader) (io.Reader, error) {
+	buffered := bufio.NewReader(r)
+	header, err := buffered.Peek(2)
+	if err != nil {
+		return nil, err
+	}
+	// zlib头: CMF低4位为8且(CMF<<8|FLG)能被31整除
+	if header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(buffered)
+	}
+	return flate.NewReader(buffered), nil
+}
+
+// zstdCloser 适配zstd.Decoder的Close方法
+type zstdCloser struct {
+	d *zstd.Decoder
+}
+
+// Close 释放zstd解压器
+func (z zstdCloser) Close() error {
+	z.d.Close()
+	return nil
+}
@@ -0,0 +1,103 @@
+package pkg
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ResponseCache 定义单个目标的响应缓存, 相同请求(方法+路径+请求头+请求体)只发送一次
+type ResponseCache struct {
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+}
+
+// cacheEntry 定义缓存项, done关闭后resp/err可读
+type cacheEntry struct {
+	done chan struct{}
+	resp *HttpResponse
+	err  error
+}
+
+// NewResponseCache 创建响应缓存
+// 返回:
+//   - *ResponseCache: 响应缓存
+func NewResponseCache() *ResponseCache {
+	return &ResponseCache{entries: make(map[string]*cacheEntry)}
+}
+
+// RequestKey 生成请求的缓存键
+// 参数:
+//   - url: 目标URL
+//   - probe: 探针配置信息
+//
+// 返回:
+//   - string: 缓存键
+//   - error: 错误信息
+func RequestKey(url string, probe Probe) (string, error) {
+	req, err := parseProbe(probe)
+	if err != nil {
+		return "", err
+	}
+
+	names := make([]string, 0, len(req.Headers))
+	for name := range req.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var key strings.Builder
+	key.WriteString(strings.ToUpper(req.Method))
+	key.WriteString(" ")
+	key.WriteString(url)
+	key.WriteString(req.Path)
+	for _, name := range names {
+		key.WriteString("\n")
+		key.WriteString(strings.ToLower(name))
+		key.WriteString(": ")
+		key.WriteString(req.Headers[name])
+	}
+	// 请求体可能较大, 使用摘要区分
+	if req.Body != "" {
+		sum := sha256.Sum256([]byte(req.Body))
+		key.WriteString("\n\n")
+		key.WriteString(hex.EncodeToString(sum[:]))
+	}
+	return key.String(), nil
+}
+
+// CachedRequest 通过缓存发送HTTP请求, 并发的相同请求只会实际发送一次
+// 参数:
+//   - cache: 响应缓存, 为空时直接发送请求
+//   - url: 目标URL地址
+//   - probe: 探针配置信息
+//
+// 返回:
+//   - *HttpResponse: 自定义的HTTP响应结构
+//   - error: 错误信息
+func (p *Probes) CachedRequest(cache *ResponseCache, url string, probe Probe) (*HttpResponse, error) {
+	if cache == nil {
+		return p.HttpRequest(url, probe)
+	}
+
+	key, err := RequestKey(url, probe)
+	if err != nil {
+		return nil, err
+	}
+
+	cache.mu.Lock()
+	if entry, ok := cache.entries[key]; ok {
+		cache.mu.Unlock()
+		<-entry.done
+		return entry.resp, entry.err
+	}
+	entry := &cacheEntry{done: make(chan struct{})}
+	cache.entries[key] = entry
+	cache.mu.Unlock()
+
+	entry.resp, entry.err = p.HttpRequest(url, probe)
+	close(entry.done)
+	return entry.resp, entry.err
+}
@@ -0,0 +1,87 @@
+package pkg
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestKey(t *testing.T) {
+	get := Probe{Data: "GET / HTTP/1.1\r\nUser-Agent: a\r\nAccept: */*\r\n\r\n"}
+	reordered := Probe{Data: "GET / HTTP/1.1\r\nAccept: */*\r\nUser-Agent: a\r\n\r\n"}
+	loginA := Probe{Data: "POST /login HTTP/1.1\r\nContent-Type: application/json\r\n\r\n{\"user\":\"a\"}"}
+	loginB := Probe{Data: "POST /login HTTP/1.1\r\nContent-Type: application/json\r\n\r\n{\"user\":\"b\"}"}
+
+	key := func(probe Probe) string {
+		k, err := RequestKey("http://example.com", probe)
+		require.NoError(t, err)
+		return k
+	}
+	assert.Equal(t, key(get), key(reordered), "请求头顺序不影响缓存键")
+	assert.NotEqual(t, key(loginA), key(loginB), "请求体不同的请求不能共用缓存")
+	assert.NotEqual(t, key(get), key(Probe{Data: "GET /admin HTTP/1.1\r\nUser-Agent: a\r\nAccept: */*\r\n\r\n"}))
+}
+
+func TestCachedRequest(t *testing.T) {
+	var hits atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		// 等待全部并发请求发出后再响应, 确保相同请求在进行中时被合并
+		<-release
+		body, _ := io.ReadAll(r.Body)
+		w.Write(append([]byte(r.Method+" "), body...))
+	}))
+	defer server.Close()
+
+	p := &Probes{Options: &HTTPOptions{}}
+	cache := NewResponseCache()
+	probes := []Probe{
+		{Data: "GET / HTTP/1.1\r\nAccept: */*\r\n\r\n", Timeout: 5},
+		{Data: "POST /login HTTP/1.1\r\n\r\nuser=a", Timeout: 5},
+		{Data: "POST /login HTTP/1.1\r\n\r\nuser=b", Timeout: 5},
+	}
+
+	// 每个请求并发发送5次
+	var wg sync.WaitGroup
+	bodies := make([][]string, len(probes))
+	var mu sync.Mutex
+	for i, probe := range probes {
+		for n := 0; n < 5; n++ {
+			wg.Add(1)
+			go func(i int, probe Probe) {
+				defer wg.Done()
+				resp, err := p.CachedRequest(cache, server.URL, probe)
+				if !assert.NoError(t, err) {
+					return
+				}
+				mu.Lock()
+				bodies[i] = append(bodies[i], string(resp.Body))
+				mu.Unlock()
+			}(i, probe)
+		}
+	}
+	assert.Eventually(t, func() bool { return hits.Load() == int32(len(probes)) }, 5*time.Second, 10*time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(len(probes)), hits.Load(), "相同请求只发送一次")
+	for i, want := range []string{"GET ", "POST user=a", "POST user=b"} {
+		assert.Len(t, bodies[i], 5)
+		for _, body := range bodies[i] {
+			assert.Equal(t, want, body)
+		}
+	}
+
+	// 已完成的请求直接使用缓存
+	_, err := p.CachedRequest(cache, server.URL, probes[0])
+	require.NoError(t, err)
+	assert.Equal(t, int32(len(probes)), hits.Load())
+}
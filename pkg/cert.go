@@ -0,0 +1,70 @@
+package pkg
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CertInfo 定义证书摘要信息
+type CertInfo struct {
+	Subject   string    // 证书主题
+	Issuer    string    // 证书颁发者
+	SANs      []string  // 证书备用名称(DNS/IP/Email)
+	Serial    string    // 证书序列号(十六进制)
+	SHA256    string    // 证书SHA256指纹
+	NotBefore time.Time // 生效时间
+	NotAfter  time.Time // 过期时间
+}
+
+// NewCertInfo 根据x509证书生成证书摘要
+// 参数:
+//   - cert: x509证书
+//
+// 返回:
+//   - *CertInfo: 证书摘要, cert为空时返回nil
+func NewCertInfo(cert *x509.Certificate) *CertInfo {
+	if cert == nil {
+		return nil
+	}
+
+	sans := make([]string, 0, len(cert.DNSNames)+len(cert.IPAddresses)+len(cert.EmailAddresses))
+	sans = append(sans, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	sans = append(sans, cert.EmailAddresses...)
+
+	sum := sha256.Sum256(cert.Raw)
+	return &CertInfo{
+		Subject:   cert.Subject.String(),
+		Issuer:    cert.Issuer.String(),
+		SANs:      sans,
+		Serial:    strings.ToLower(cert.SerialNumber.Text(16)),
+		SHA256:    hex.EncodeToString(sum[:]),
+		NotBefore: cert.NotBefore,
+		NotAfter:  cert.NotAfter,
+	}
+}
+
+// String 返回证书摘要的字符串表示
+func (c *CertInfo) String() string {
+	if c == nil {
+		return ""
+	}
+	return fmt.Sprintf("subject=%s; issuer=%s; san=%s; serial=%s; sha256=%s",
+		c.Subject, c.Issuer, strings.Join(c.SANs, ","), c.Serial, c.SHA256)
+}
+
+// CertInfo 获取响应中对端叶子证书的摘要
+// 返回:
+//   - *CertInfo: 证书摘要, 非TLS响应返回nil
+func (r *HttpResponse) CertInfo() *CertInfo {
+	if r == nil || len(r.Certificates) == 0 {
+		return nil
+	}
+	return NewCertInfo(r.Certificates[0])
+}
@@ -0,0 +1,99 @@
+package pkg
+
+import "strings"
+
+// RuleFilter 定义加载时选择指纹规则子集的条件, 各条件同时满足时规则才保留, 零值不筛选
+type RuleFilter struct {
+	Tags        []string // 包含任一标签的规则, 为空时不限制
+	ExcludeTags []string // 排除包含任一标签的规则, 优先于Tags
+	Severities  []string // 严重程度为其中之一的规则, 为空时不限制
+	IDs         []string // 规则ID为其中之一的规则, 为空时不限制
+}
+
+// Empty 判断是否未设置任何筛选条件
+// 返回:
+//   - bool: 是否未设置筛选条件
+func (f RuleFilter) Empty() bool {
+	return len(f.Tags) == 0 && len(f.ExcludeTags) == 0 && len(f.Severities) == 0 && len(f.IDs) == 0
+}
+
+// Apply 按筛选条件选择指纹规则
+// 参数:
+//   - tags: 指纹规则
+//
+// 返回:
+//   - []Tag: 符合条件的指纹规则, 保持原顺序
+func (f RuleFilter) Apply(tags []Tag) []Tag {
+	if f.Empty() {
+		return tags
+	}
+	selected := make([]Tag, 0, len(tags))
+	for _, tag := range tags {
+		if f.Match(tag) {
+			selected = append(selected, tag)
+		}
+	}
+	return selected
+}
+
+// Match 判断指纹规则是否符合筛选条件, 标签与严重程度忽略大小写, 规则ID区分大小写
+// 参数:
+//   - tag: 指纹规则
+//
+// 返回:
+//   - bool: 是否符合
+func (f RuleFilter) Match(tag Tag) bool {
+	labels := splitList(tag.Info.Tags)
+	if containsAny(labels, f.ExcludeTags) {
+		return false
+	}
+	if len(f.Tags) > 0 && !containsAny(labels, f.Tags) {
+		return false
+	}
+	if len(f.Severities) > 0 && !containsAny([]string{tag.Info.Severity}, f.Severities) {
+		return false
+	}
+	if len(f.IDs) > 0 {
+		for _, id := range f.IDs {
+			if strings.TrimSpace(id) == tag.ID {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// splitList 拆分以逗号分隔的列表, 忽略空项
+// 参数:
+//   - value: 以逗号分隔的列表
+//
+// 返回:
+//   - []string: 列表项
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// containsAny 判断两个列表是否存在忽略大小写相同的项
+// 参数:
+//   - items: 待检查的列表
+//   - wanted: 条件列表
+//
+// 返回:
+//   - bool: 是否存在相同的项
+func containsAny(items, wanted []string) bool {
+	for _, item := range items {
+		for _, w := range wanted {
+			if w = strings.TrimSpace(w); w != "" && strings.EqualFold(item, w) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package pkg
+
+import (
+	"net/http"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// maxRedirects 服务端跳转的最大次数
+const maxRedirects = 15
+
+// redirectRecorder 定义服务端跳转策略, 记录每一跳的30x响应
+type redirectRecorder struct {
+	limiter *Limiter
+	hops    []*HttpResponse
+}
+
+// reset 清空已记录的跳转, 每次发出请求(包括重试)前调用
+func (r *redirectRecorder) reset() {
+	r.hops = nil
+}
+
+// Apply 实现resty.RedirectPolicy, 在跟随跳转前记录触发跳转的响应
+// 参数:
+//   - req: 即将发送的跳转请求
+//   - via: 已发送的请求
+//
+// 返回:
+//   - error: 超过最大跳转次数时返回错误
+func (r *redirectRecorder) Apply(req *http.Request, via []*http.Request) error {
+	// 跳转次数限制与同主机请求头继承沿用resty的策略
+	if err := resty.FlexibleRedirectPolicy(maxRedirects).Apply(req, via); err != nil {
+		return err
+	}
+
+	if resp := req.Response; resp != nil {
+		hop := &HttpResponse{
+			Status:     resp.Status,
+			StatusCode: resp.StatusCode,
+			Header:     restoreEncoding(resp.Header),
+		}
+		if resp.Request != nil {
+			hop.URL = resp.Request.URL.String()
+		}
+		if resp.TLS != nil {
+			hop.Certificates = resp.TLS.PeerCertificates
+		}
+		r.hops = append(r.hops, hop)
+	}
+
+	// 跳转请求同样遵守全局速率与主机间隔
+	r.limiter.Wait(requestHost(req.URL.String()))
+	return nil
+}
@@ -0,0 +1,372 @@
+package pkg
+
+import (
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// RuleIndex 定义指纹规则的预过滤索引
+// 字面量关键字编入Aho-Corasick自动机, 正则关键字预编译, 每个响应部位只扫描一次即可得到候选指纹
+type RuleIndex struct {
+	groups  map[indexKey]*indexGroup
+	always  []int // 无法预过滤、每次都需要完整匹配的指纹
+	numTags int
+}
+
+// indexKey 定义索引分组, 同一部位与跳转设置的关键字共用一个自动机
+type indexKey struct {
+	part string
+	hops bool
+}
+
+// indexGroup 定义单个部位的关键字索引
+type indexGroup struct {
+	literals    []string         // 字面量关键字(小写)
+	literalTags [][]int          // 字面量关键字ID到指纹下标
+	regexps     []*regexp.Regexp // 预编译的正则关键字
+	regexpTags  [][]int          // 正则关键字ID到指纹下标
+	gated       []bool           // 正则是否存在必需字面量, 不存在时每次都要执行
+	gates       []string         // 正则必需包含的字面量(小写)
+	gateRegexps [][]int          // 必需字面量ID到正则ID
+
+	ac *AhoCorasick // 字面量关键字与必需字面量共用的自动机, 必需字面量ID从len(literals)开始
+}
+
+// ContentFunc 定义获取响应部位内容的函数
+// 参数:
+//   - part: 匹配部位
+//   - hops: 是否包含服务端跳转的中间响应
+//
+// 返回:
+//   - string: 部位内容
+//   - bool: 响应中是否存在该部位
+type ContentFunc func(part string, hops bool) (string, bool)
+
+// indexScratch 定义预过滤使用的临时标记, 通过RuleSet的对象池复用
+type indexScratch struct {
+	marked []bool // 候选指纹
+	opened []bool // 必需字面量已出现的正则
+}
+
+// newRuleIndex 根据指纹规则构建预过滤索引
+// 参数:
+//   - tags: 指纹规则
+//   - compile: 正则编译函数
+//
+// 返回:
+//   - *RuleIndex: 预过滤索引
+func newRuleIndex(tags []Tag, compile func(pattern string) (*regexp.Regexp, error)) *RuleIndex {
+	idx := &RuleIndex{
+		groups:  make(map[indexKey]*indexGroup),
+		numTags: len(tags),
+	}
+
+	literalIDs := make(map[indexKey]map[string]int)
+	regexpIDs := make(map[indexKey]map[string]int)
+	gateIDs := make(map[indexKey]map[string]int)
+	for i, tag := range tags {
+		always := false
+		for _, http := range tag.HTTP {
+			// 各HTTP块独立成立, 只有取反匹配器的块无法筛选
+			positive, negative := 0, 0
+			for _, matcher := range http.Matchers {
+				if matcher.Negative {
+					negative++
+				} else {
+					positive++
+				}
+			}
+			if negative > 0 && positive == 0 {
+				always = true
+			}
+
+			for _, matcher := range http.Matchers {
+				// 取反匹配器在关键字缺失时成立, 不能据此筛选; 位于or块中时指纹总是候选
+				if matcher.Negative {
+					if http.Mode != "and" {
+						always = true
+					}
+					continue
+				}
+
+				words := indexWords(matcher)
+				key := indexKey{part: matcher.Part, hops: matcher.Hops}
+				switch matcher.Type {
+				case "word":
+					// 没有关键字的匹配器总是成立
+					if len(words) == 0 {
+						always = true
+						continue
+					}
+				case "favicon":
+					// 哈希按字面量编入索引, 完整匹配时再比较整行
+					if len(matcher.Hash) == 0 {
+						continue
+					}
+					key = indexKey{part: FaviconPart}
+					words = make([]string, len(matcher.Hash))
+					for w, hash := range matcher.Hash {
+						words[w] = regexp.QuoteMeta(strings.TrimSpace(hash))
+					}
+				default:
+					continue
+				}
+
+				group, ok := idx.groups[key]
+				if !ok {
+					group = &indexGroup{}
+					idx.groups[key] = group
+					literalIDs[key] = make(map[string]int)
+					regexpIDs[key] = make(map[string]int)
+					gateIDs[key] = make(map[string]int)
+				}
+
+				for _, word := range words {
+					pattern := "(?i)(" + word + ")"
+					if literal, ok := literalOf(pattern); ok {
+						id, ok := literalIDs[key][literal]
+						if !ok {
+							id = len(group.literals)
+							literalIDs[key][literal] = id
+							group.literals = append(group.literals, literal)
+							group.literalTags = append(group.literalTags, nil)
+						}
+						group.literalTags[id] = appendTag(group.literalTags[id], i)
+						continue
+					}
+
+					// 无法编译的关键字在匹配时同样不会命中, 直接忽略
+					id, ok := regexpIDs[key][pattern]
+					if !ok {
+						re, err := compile(pattern)
+						if err != nil {
+							continue
+						}
+						id = len(group.regexps)
+						regexpIDs[key][pattern] = id
+						group.regexps = append(group.regexps, re)
+						group.regexpTags = append(group.regexpTags, nil)
+
+						// 正则必需包含的字面量未出现时无需执行正则
+						gate, ok := requiredLiteral(pattern)
+						group.gated = append(group.gated, ok)
+						if ok {
+							gateID, exists := gateIDs[key][gate]
+							if !exists {
+								gateID = len(group.gates)
+								gateIDs[key][gate] = gateID
+								group.gates = append(group.gates, gate)
+								group.gateRegexps = append(group.gateRegexps, nil)
+							}
+							group.gateRegexps[gateID] = append(group.gateRegexps[gateID], id)
+						}
+					}
+					group.regexpTags[id] = appendTag(group.regexpTags[id], i)
+				}
+			}
+		}
+		if always {
+			idx.always = append(idx.always, i)
+		}
+	}
+
+	for _, group := range idx.groups {
+		patterns := make([]string, 0, len(group.literals)+len(group.gates))
+		patterns = append(patterns, group.literals...)
+		patterns = append(patterns, group.gates...)
+		group.ac = NewAhoCorasick(patterns)
+	}
+	return idx
+}
+
+// newScratch 创建与索引规模匹配的临时标记
+// 返回:
+//   - *indexScratch: 临时标记
+func (idx *RuleIndex) newScratch() *indexScratch {
+	maxRegexps := 0
+	for _, group := range idx.groups {
+		if len(group.regexps) > maxRegexps {
+			maxRegexps = len(group.regexps)
+		}
+	}
+	return &indexScratch{
+		marked: make([]bool, idx.numTags),
+		opened: make([]bool, maxRegexps),
+	}
+}
+
+// mark 标记可能匹配响应的候选指纹
+// 参数:
+//   - content: 获取响应部位内容的函数
+//   - scratch: 临时标记, 结果写入scratch.marked
+func (idx *RuleIndex) mark(content ContentFunc, scratch *indexScratch) {
+	marked := scratch.marked
+	clear(marked)
+	for _, i := range idx.always {
+		marked[i] = true
+	}
+
+	for key, group := range idx.groups {
+		text, ok := content(key.part, key.hops)
+		if !ok {
+			continue
+		}
+		opened := scratch.opened[:len(group.regexps)]
+		clear(opened)
+		group.ac.Scan(text, func(id int) {
+			if id < len(group.literals) {
+				for _, i := range group.literalTags[id] {
+					marked[i] = true
+				}
+				return
+			}
+			for _, re := range group.gateRegexps[id-len(group.literals)] {
+				opened[re] = true
+			}
+		})
+		for id, re := range group.regexps {
+			if group.gated[id] && !opened[id] {
+				continue
+			}
+			// 关联的指纹均已是候选时无需执行正则
+			pending := false
+			for _, i := range group.regexpTags[id] {
+				if !marked[i] {
+					pending = true
+					break
+				}
+			}
+			if pending && re.MatchString(text) {
+				for _, i := range group.regexpTags[id] {
+					marked[i] = true
+				}
+			}
+		}
+	}
+}
+
+// indexWords 获取匹配器需要编入索引的关键字
+// and条件要求全部关键字命中, 只需索引其中最具区分度的一个
+// 参数:
+//   - matcher: 匹配器
+//
+// 返回:
+//   - []string: 需要索引的关键字
+func indexWords(matcher Matchers) []string {
+	if matcher.Condition != "and" || len(matcher.Words) <= 1 {
+		return matcher.Words
+	}
+
+	best, bestScore := matcher.Words[0], -1
+	for _, word := range matcher.Words {
+		pattern := "(?i)(" + word + ")"
+		score := 0
+		if literal, ok := literalOf(pattern); ok {
+			// 字面量优先, 越长越少误报
+			score = 2*len(literal) + 1
+		} else if gate, ok := requiredLiteral(pattern); ok {
+			score = 2 * len(gate)
+		}
+		if score > bestScore {
+			best, bestScore = word, score
+		}
+	}
+	return []string{best}
+}
+
+// literalOf 判断正则是否等价于忽略大小写的字面量
+// 参数:
+//   - pattern: 正则表达式
+//
+// 返回:
+//   - string: 小写的字面量
+//   - bool: 是否为字面量
+func literalOf(pattern string) (string, bool) {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return "", false
+	}
+	re = re.Simplify()
+	if re.Op == syntax.OpCapture && len(re.Sub) == 1 {
+		re = re.Sub[0]
+	}
+	if re.Op != syntax.OpLiteral || len(re.Rune) == 0 {
+		return "", false
+	}
+	// 自动机只折叠ASCII大小写, 含其他大小写字母时仍使用正则
+	return foldableLiteral(re.Rune)
+}
+
+// requiredLiteral 提取正则匹配时必然出现的最长字面量
+// 参数:
+//   - pattern: 正则表达式
+//
+// 返回:
+//   - string: 小写的字面量
+//   - bool: 是否存在可用的字面量
+func requiredLiteral(pattern string) (string, bool) {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return "", false
+	}
+	re = re.Simplify()
+	for re.Op == syntax.OpCapture && len(re.Sub) == 1 {
+		re = re.Sub[0]
+	}
+
+	// 仅处理顶层连接, 取其中最长的字面量片段
+	var subs []*syntax.Regexp
+	switch re.Op {
+	case syntax.OpConcat:
+		subs = re.Sub
+	case syntax.OpLiteral:
+		subs = []*syntax.Regexp{re}
+	default:
+		return "", false
+	}
+
+	best := ""
+	for _, sub := range subs {
+		for sub.Op == syntax.OpCapture && len(sub.Sub) == 1 {
+			sub = sub.Sub[0]
+		}
+		if sub.Op != syntax.OpLiteral {
+			continue
+		}
+		if literal, ok := foldableLiteral(sub.Rune); ok && len(literal) > len(best) {
+			best = literal
+		}
+	}
+	// 过短的字面量过滤效果有限
+	if len(best) < 3 {
+		return "", false
+	}
+	return best, true
+}
+
+// foldableLiteral 判断字面量能否由自动机按ASCII大小写折叠匹配
+// 参数:
+//   - runes: 字面量
+//
+// 返回:
+//   - string: 小写的字面量
+//   - bool: 是否可用
+func foldableLiteral(runes []rune) (string, bool) {
+	for _, r := range runes {
+		if r >= utf8.RuneSelf && unicode.SimpleFold(r) != r {
+			return "", false
+		}
+	}
+	return strings.ToLower(string(runes)), true
+}
+
+// appendTag 追加指纹下标, 同一指纹不重复追加
+func appendTag(tags []int, i int) []int {
+	if len(tags) > 0 && tags[len(tags)-1] == i {
+		return tags
+	}
+	return append(tags, i)
+}
@@ -0,0 +1,115 @@
+package pkg
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Limiter 定义全局速率限制与单主机礼貌策略
+type Limiter struct {
+	global          *rate.Limiter // 全局每秒请求数限制, 为空时不限制
+	hostConcurrency int           // 单主机最大并发请求数, 0表示不限制
+	hostDelay       time.Duration // 单主机相邻请求的最小间隔
+
+	mu    sync.Mutex
+	hosts map[string]*hostState
+}
+
+// hostState 定义单个主机的限流状态
+type hostState struct {
+	sem  chan struct{} // 并发信号量
+	mu   sync.Mutex    // 保护last
+	last time.Time     // 上次请求时间
+}
+
+// NewLimiter 创建限流器
+// 参数:
+//   - rps: 全局每秒请求数, <=0 表示不限制
+//   - hostConcurrency: 单主机最大并发请求数, <=0 表示不限制
+//   - hostDelay: 单主机相邻请求的最小间隔
+//
+// 返回:
+//   - *Limiter: 限流器, 所有限制均关闭时返回nil
+func NewLimiter(rps float64, hostConcurrency int, hostDelay time.Duration) *Limiter {
+	if rps <= 0 && hostConcurrency <= 0 && hostDelay <= 0 {
+		return nil
+	}
+
+	l := &Limiter{
+		hostConcurrency: hostConcurrency,
+		hostDelay:       hostDelay,
+		hosts:           make(map[string]*hostState),
+	}
+	if rps > 0 {
+		burst := int(rps)
+		if burst < 1 {
+			burst = 1
+		}
+		l.global = rate.NewLimiter(rate.Limit(rps), burst)
+	}
+	return l
+}
+
+// host 获取主机的限流状态
+func (l *Limiter) host(host string) *hostState {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	state, ok := l.hosts[host]
+	if !ok {
+		state = &hostState{}
+		if l.hostConcurrency > 0 {
+			state.sem = make(chan struct{}, l.hostConcurrency)
+		}
+		l.hosts[host] = state
+	}
+	return state
+}
+
+// Acquire 占用主机的一个并发名额, 名额不足时阻塞直到有空闲名额或ctx结束
+// 参数:
+//   - ctx: 等待名额的上下文
+//   - host: 主机(host:port)
+//
+// 返回:
+//   - func(): 释放名额的函数
+//   - error: ctx结束时返回其错误
+func (l *Limiter) Acquire(ctx context.Context, host string) (func(), error) {
+	if l == nil || l.hostConcurrency <= 0 {
+		return func() {}, nil
+	}
+
+	state := l.host(host)
+	select {
+	case state.sem <- struct{}{}:
+		return func() { <-state.sem }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Wait 在发出单次请求前等待全局速率与主机间隔
+// 参数:
+//   - host: 主机(host:port)
+func (l *Limiter) Wait(host string) {
+	if l == nil {
+		return
+	}
+
+	if l.hostDelay > 0 {
+		state := l.host(host)
+		state.mu.Lock()
+		if wait := time.Until(state.last.Add(l.hostDelay)); wait > 0 {
+			time.Sleep(wait)
+		}
+		state.last = time.Now()
+		state.mu.Unlock()
+	}
+
+	if l.global != nil {
+		l.global.Wait(context.Background())
+	}
+}
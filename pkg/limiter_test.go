@@ -0,0 +1,108 @@
+package pkg
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var limiterProbe = Probe{Data: "GET / HTTP/1.1\r\nAccept: */*\r\n\r\n", Timeout: 5}
+
+func TestHostConcurrency(t *testing.T) {
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mu.Unlock()
+
+		time.Sleep(50 * time.Millisecond)
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	p := &Probes{Options: &HTTPOptions{HostConcurrency: 2}}
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := p.HttpRequest(server.URL, limiterProbe)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 2, maxInFlight, "同一主机的并发请求数不超过上限")
+}
+
+func TestHostDelay(t *testing.T) {
+	var mu sync.Mutex
+	var arrivals []time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		arrivals = append(arrivals, time.Now())
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	delay := 100 * time.Millisecond
+	p := &Probes{Options: &HTTPOptions{HostDelay: delay}}
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := p.HttpRequest(server.URL, limiterProbe)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	require.Len(t, arrivals, 3)
+	for i := 1; i < len(arrivals); i++ {
+		// 间隔在发出请求前计算, 到达时间允许少量抖动
+		assert.GreaterOrEqual(t, arrivals[i].Sub(arrivals[i-1]), delay-10*time.Millisecond, "相邻请求的间隔")
+	}
+}
+
+func TestAcquireContext(t *testing.T) {
+	l := NewLimiter(0, 1, 0)
+	release, err := l.Acquire(context.Background(), "a:80")
+	require.NoError(t, err)
+
+	// 名额已满时等待到ctx结束
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = l.Acquire(ctx, "a:80")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// 其他主机不受影响
+	other, err := l.Acquire(context.Background(), "b:80")
+	if assert.NoError(t, err) {
+		other()
+	}
+
+	// 释放后可以再次占用
+	release()
+	again, err := l.Acquire(context.Background(), "a:80")
+	require.NoError(t, err)
+	again()
+
+	// 未启用限制时不阻塞
+	var none *Limiter
+	noop, err := none.Acquire(context.Background(), "a:80")
+	require.NoError(t, err)
+	noop()
+}
@@ -0,0 +1,221 @@
+package pkg
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/enenisme/definger/logger"
+)
+
+// TLSProfile 定义TLS客户端配置
+type TLSProfile struct {
+	Name         string            // 配置名称, 用于日志
+	CipherSuites []uint16          // 加密套件, 为空时使用Go默认套件
+	MinVersion   uint16            // 最低TLS版本
+	MaxVersion   uint16            // 最高TLS版本
+	DisableSNI   bool              // 是否禁用SNI
+	ServerName   string            // SNI覆盖, 为空时使用目标主机名
+	Certificates []tls.Certificate // 客户端证书
+}
+
+// HTTPOptions 定义共享HTTP层的配置
+type HTTPOptions struct {
+	TLS             *TLSProfile // TLS客户端配置, 为空时使用DefaultTLSProfile
+	DisableFallback bool        // 是否禁用握手失败后回退到LegacyTLSProfile
+	Proxies         []string    // 代理地址列表, 多个代理时按请求轮询
+
+	RateLimit       float64       // 全局每秒请求数限制, 0表示不限制
+	HostConcurrency int           // 单主机最大并发请求数, 0表示不限制
+	HostDelay       time.Duration // 单主机相邻请求的最小间隔
+
+	MaxBodySize int64 // 响应体读取上限(字节), 0表示使用DefaultMaxBodySize
+
+	Logger *logger.Logger // 日志记录器, 为空时不输出调试信息
+
+	limiterOnce  sync.Once
+	limiter      *Limiter
+	proxyCounter atomic.Uint64 // 代理轮询计数器, 各配置独立轮询
+}
+
+// DefaultTLSProfile 默认TLS配置, 仅使用现代AEAD加密套件
+// 返回:
+//   - *TLSProfile: TLS配置
+func DefaultTLSProfile() *TLSProfile {
+	return &TLSProfile{
+		Name:       "modern",
+		MinVersion: tls.VersionTLS10,
+		MaxVersion: tls.VersionTLS13,
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
+		},
+	}
+}
+
+// LegacyTLSProfile 兼容老旧设备的TLS配置, 包含CBC/RC4/3DES等不安全套件
+// 返回:
+//   - *TLSProfile: TLS配置
+func LegacyTLSProfile() *TLSProfile {
+	var suites []uint16
+	for _, suite := range tls.CipherSuites() {
+		suites = append(suites, suite.ID)
+	}
+	for _, suite := range tls.InsecureCipherSuites() {
+		suites = append(suites, suite.ID)
+	}
+	return &TLSProfile{
+		Name:         "legacy",
+		MinVersion:   tls.VersionTLS10,
+		MaxVersion:   tls.VersionTLS12,
+		CipherSuites: suites,
+	}
+}
+
+// Config 根据配置生成tls.Config
+// 返回:
+//   - *tls.Config: TLS配置
+func (t *TLSProfile) Config() *tls.Config {
+	config := &tls.Config{
+		InsecureSkipVerify: true,
+		MinVersion:         t.MinVersion,
+		MaxVersion:         t.MaxVersion,
+		CipherSuites:       t.CipherSuites,
+		Certificates:       t.Certificates,
+	}
+	if !t.DisableSNI {
+		config.ServerName = t.ServerName
+	}
+	return config
+}
+
+// SNI 获取访问指定主机时发送的SNI
+// 参数:
+//   - host: 目标主机名
+//
+// 返回:
+//   - string: SNI, 禁用SNI时为空
+func (t *TLSProfile) SNI(host string) string {
+	if t.DisableSNI {
+		return ""
+	}
+	if t.ServerName != "" {
+		return t.ServerName
+	}
+	return host
+}
+
+// SNI 获取当前TLS配置下访问指定主机时发送的SNI, 供JARM等自行构造握手的探测使用
+// 参数:
+//   - host: 目标主机名
+//
+// 返回:
+//   - string: SNI, 禁用SNI时为空
+func (o *HTTPOptions) SNI(host string) string {
+	return o.tlsProfile().SNI(host)
+}
+
+// tlsProfile 获取当前生效的TLS配置
+func (o *HTTPOptions) tlsProfile() *TLSProfile {
+	if o == nil || o.TLS == nil {
+		return DefaultTLSProfile()
+	}
+	return o.TLS
+}
+
+// Limiter 获取共享限流器, 首次调用时根据配置创建
+// 返回:
+//   - *Limiter: 限流器, 未配置限流时返回nil
+func (o *HTTPOptions) Limiter() *Limiter {
+	if o == nil {
+		return nil
+	}
+	o.limiterOnce.Do(func() {
+		o.limiter = NewLimiter(o.RateLimit, o.HostConcurrency, o.HostDelay)
+	})
+	return o.limiter
+}
+
+// maxBodySize 获取响应体读取上限
+func (o *HTTPOptions) maxBodySize() int64 {
+	if o == nil || o.MaxBodySize <= 0 {
+		return DefaultMaxBodySize
+	}
+	return o.MaxBodySize
+}
+
+// debugLogger 获取日志记录器
+func (o *HTTPOptions) debugLogger() *logger.Logger {
+	if o == nil {
+		return nil
+	}
+	return o.Logger
+}
+
+// fallbackEnabled 是否启用握手失败回退
+func (o *HTTPOptions) fallbackEnabled() bool {
+	return o == nil || !o.DisableFallback
+}
+
+// ParseTLSVersion 解析TLS版本字符串
+// 参数:
+//   - version: 版本字符串, 例如 1.0, 1.2, tls1.3
+//
+// 返回:
+//   - uint16: TLS版本
+//   - error: 错误信息
+func ParseTLSVersion(version string) (uint16, error) {
+	v := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(version)), "tls")
+	v = strings.TrimPrefix(v, "v")
+	switch v {
+	case "1.0", "1", "10":
+		return tls.VersionTLS10, nil
+	case "1.1", "11":
+		return tls.VersionTLS11, nil
+	case "1.2", "12":
+		return tls.VersionTLS12, nil
+	case "1.3", "13":
+		return tls.VersionTLS13, nil
+	}
+	return 0, fmt.Errorf("不支持的TLS版本: %s", version)
+}
+
+// ParseCipherSuites 解析逗号分隔的加密套件列表
+// 参数:
+//   - ciphers: 套件名称(如TLS_RSA_WITH_AES_128_CBC_SHA)或十六进制ID(如0x002f)
+//
+// 返回:
+//   - []uint16: 加密套件ID列表
+//   - error: 错误信息
+func ParseCipherSuites(ciphers string) ([]uint16, error) {
+	known := make(map[string]uint16)
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		known[suite.Name] = suite.ID
+	}
+
+	var suites []uint16
+	for _, name := range strings.Split(ciphers, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if id, ok := known[strings.ToUpper(name)]; ok {
+			suites = append(suites, id)
+			continue
+		}
+		id, err := strconv.ParseUint(name, 0, 16)
+		if err != nil {
+			return nil, fmt.Errorf("未知的加密套件: %s", name)
+		}
+		suites = append(suites, uint16(id))
+	}
+	return suites, nil
+}
@@ -1,10 +1,16 @@
 package pkg
 
 import (
+	"context"
 	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	neturl "net/url"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-resty/resty/v2"
@@ -23,15 +29,24 @@ type Probe struct {
 
 // Probes 定义配置结构
 type Probes struct {
-	Probes map[string]Probe `toml:"probes"`
+	Probes  map[string]Probe `toml:"probes"`
+	Options *HTTPOptions     `toml:"-"` // 共享HTTP层配置
+
+	legacyHosts sync.Map // 需要回退到兼容TLS配置的主机
 }
 
 // HttpResponse 定义HTTP响应结构
 type HttpResponse struct {
+	URL        string // 响应对应的最终请求URL
 	Status     string
 	StatusCode int
 	Header     http.Header
 	Body       []byte
+
+	Certificates []*x509.Certificate // 对端证书链(仅HTTPS)
+	JARM         string              // 目标TLS服务的JARM指纹(需开启JARM探测)
+
+	Hops []*HttpResponse // 服务端跳转经过的中间响应(按跳转顺序, 不含响应体)
 }
 
 // HttpRequest 发送HTTP请求到指定URL
@@ -55,8 +70,88 @@ func (p *Probes) HttpRequest(url string, probe Probe) (*HttpResponse, error) {
 //   - *HttpResponse: 自定义的HTTP响应结构
 //   - error: 错误信息
 func (p *Probes) sendHTTPRequest(url string, probe Probe) (*HttpResponse, error) {
+	req, err := parseProbe(probe)
+	if err != nil {
+		return nil, err
+	}
+
+	// 使用strings.Builder拼接URL,避免字符串拼接的内存分配
+	var urlBuilder strings.Builder
+	urlBuilder.WriteString(url)
+	urlBuilder.WriteString(req.Path)
+	finalURL := urlBuilder.String()
+	headers := req.Headers
+
+	// 已知只支持老旧TLS的主机直接使用兼容配置
+	profile := p.Options.tlsProfile()
+	host := requestHost(finalURL)
+	if _, legacy := p.legacyHosts.Load(host); legacy && p.Options.fallbackEnabled() {
+		profile = fallbackProfile(profile)
+	}
+
+	// 占用主机并发名额, 重试与回退均计入同一名额
+	// 等待名额的时间不超过单次请求的超时时间
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout(probe))
+	release, err := p.Options.Limiter().Acquire(ctx, host)
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("等待主机 %s 的并发名额超时: %v", host, err)
+	}
+	defer release()
+
+	// 执行请求, 仅在探针定义了请求体时发送, 避免为无请求体的请求添加Content-Type
+	execute := func(profile *TLSProfile, recorder *redirectRecorder) (*resty.Response, error) {
+		r := p.newClient(probe, profile, recorder).R().SetHeaders(headers)
+		if req.Body != "" {
+			r.SetBody(req.Body)
+		}
+		return r.Execute(req.Method, finalURL)
+	}
+	recorder := &redirectRecorder{limiter: p.Options.Limiter()}
+	resp, err := execute(profile, recorder)
+
+	// 握手失败时回退到兼容配置重试
+	if err != nil && p.Options.fallbackEnabled() && profile.Name != "legacy" && isTLSHandshakeError(err) {
+		legacy := fallbackProfile(profile)
+		recorder = &redirectRecorder{limiter: p.Options.Limiter()}
+		resp, err = execute(legacy, recorder)
+		if err == nil {
+			p.legacyHosts.Store(host, struct{}{})
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("请求执行失败: %v", err)
+	}
+
+	httpResp, err := requestHandle(resp)
+	if err != nil {
+		return nil, err
+	}
+	httpResp.Hops = recorder.hops
+	return httpResp, nil
+}
+
+// probeRequest 定义从探针数据解析出的请求
+type probeRequest struct {
+	Method  string            // 请求方法
+	Path    string            // 请求路径
+	Headers map[string]string // 请求头
+	Body    string            // 请求体
+}
+
+// parseProbe 解析探针数据中的请求行、请求头和请求体
+// 参数:
+//   - probe: 探针配置信息
+//
+// 返回:
+//   - *probeRequest: 解析后的请求
+//   - error: 错误信息
+func parseProbe(probe Probe) (*probeRequest, error) {
+	// 空行之后为请求体
+	head, body, _ := strings.Cut(probe.Data, "\r\n\r\n")
+
 	// 预分配合适大小的切片避免多次扩容
-	lines := strings.Split(probe.Data, "\r\n")
+	lines := strings.Split(head, "\r\n")
 	if len(lines) < 1 {
 		return nil, fmt.Errorf("探针数据无效")
 	}
@@ -68,28 +163,47 @@ func (p *Probes) sendHTTPRequest(url string, probe Probe) (*HttpResponse, error)
 		return nil, fmt.Errorf("请求行格式无效")
 	}
 
-	// 使用strings.Builder拼接URL,避免字符串拼接的内存分配
-	var urlBuilder strings.Builder
-	urlBuilder.WriteString(url)
-	urlBuilder.WriteString(parts[1])
-	finalURL := urlBuilder.String()
+	// 预分配headers容量,避免map扩容
+	headers := make(map[string]string, len(lines)-1)
+	for _, line := range lines[1:] {
+		if line == "" {
+			continue
+		}
+		if headerParts := strings.SplitN(line, ": ", 2); len(headerParts) == 2 {
+			headers[headerParts[0]] = headerParts[1]
+		}
+	}
+
+	return &probeRequest{Method: parts[0], Path: parts[1], Headers: headers, Body: body}, nil
+}
+
+// probeTimeout 获取探针的请求超时时间
+// 参数:
+//   - probe: 探针配置信息
+//
+// 返回:
+//   - time.Duration: 超时时间, 未配置时为30秒
+func probeTimeout(probe Probe) time.Duration {
+	if probe.Timeout <= 0 {
+		return 30 * time.Second
+	}
+	return time.Duration(probe.Timeout) * time.Second
+}
+
+// newClient 根据TLS配置创建resty客户端
+// 参数:
+//   - probe: 探针配置信息
+//   - profile: TLS配置
+//   - recorder: 服务端跳转记录器
+//
+// 返回:
+//   - *resty.Client: resty客户端
+func (p *Probes) newClient(probe Probe, profile *TLSProfile, recorder *redirectRecorder) *resty.Client {
+	tlsConfig := profile.Config()
 
-	// 复用resty客户端以减少资源消耗
 	client := resty.New().
-		SetTimeout(time.Duration(probe.Timeout) * time.Second).
-		SetTLSClientConfig(&tls.Config{
-			InsecureSkipVerify: true,
-			MinVersion:         tls.VersionTLS10,
-			MaxVersion:         tls.VersionTLS13,
-			CipherSuites: []uint16{
-				tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
-				tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
-				tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
-				tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
-				tls.TLS_RSA_WITH_AES_128_GCM_SHA256,
-				tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
-			},
-		}).
+		SetTimeout(probeTimeout(probe)).
+		SetTLSClientConfig(tlsConfig).
 		// 设置重试策略
 		SetRetryCount(1).                     // 最多重试1次
 		SetRetryWaitTime(2 * time.Second).    // 重试等待1秒
@@ -109,32 +223,114 @@ func (p *Probes) sendHTTPRequest(url string, probe Probe) (*HttpResponse, error)
 			}
 			return r.StatusCode() >= 500 // 服务器错误时重试
 		}).
-		SetRedirectPolicy(resty.FlexibleRedirectPolicy(15))
+		SetRedirectPolicy(recorder)
 
-	if probe.Timeout <= 0 {
-		client.SetTimeout(30 * time.Second)
+	// 每次实际发出请求(包括重试)前清空上次尝试的跳转链
+	client.OnBeforeRequest(func(c *resty.Client, r *resty.Request) error {
+		recorder.reset()
+		return nil
+	})
+
+	// 每次实际发出请求(包括重试)前等待全局速率与主机间隔
+	if limiter := p.Options.Limiter(); limiter != nil {
+		client.OnBeforeRequest(func(c *resty.Client, r *resty.Request) error {
+			limiter.Wait(requestHost(r.URL))
+			return nil
+		})
 	}
 
-	// 预分配headers容量,避免map扩容
-	headers := make(map[string]string, len(lines)-1)
-	for _, line := range lines[1:] {
-		if line == "" {
-			continue
-		}
-		if headerParts := strings.SplitN(line, ": ", 2); len(headerParts) == 2 {
-			headers[headerParts[0]] = headerParts[1]
+	// 设置代理, 多个代理时轮询使用
+	proxyURL := p.Options.nextProxy()
+	if proxyURL != "" {
+		client.SetProxy(proxyURL)
+	}
+
+	// 禁用SNI时自行完成TLS握手, 避免Transport自动填充ServerName.
+	// HTTPS请求不再交给Transport的代理处理(CONNECT后会重新填充ServerName), 由拨号函数经同一代理建立连接
+	if profile.DisableSNI {
+		if transport, err := client.Transport(); err == nil {
+			dial, dialErr := proxyDialFunc(proxyURL, client.GetClient().Timeout)
+			proxyFunc := transport.Proxy
+			transport.Proxy = func(r *http.Request) (*neturl.URL, error) {
+				if r.URL.Scheme == "https" || proxyFunc == nil {
+					return nil, nil
+				}
+				return proxyFunc(r)
+			}
+			transport.DialTLSContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+				// 代理配置无效时不能退回直连, 否则会暴露本机地址
+				if dialErr != nil {
+					return nil, dialErr
+				}
+				conn, err := dial(network, addr)
+				if err != nil {
+					return nil, err
+				}
+				tlsConn := tls.Client(conn, tlsConfig)
+				if err := tlsConn.HandshakeContext(ctx); err != nil {
+					conn.Close()
+					return nil, err
+				}
+				return tlsConn, nil
+			}
 		}
 	}
 
-	// 执行请求
-	resp, err := client.R().
-		SetHeaders(headers).
-		Execute(parts[0], finalURL)
-	if err != nil {
-		return nil, fmt.Errorf("请求执行失败: %v", err)
+	// 在Transport层解压响应体并限制读取大小, 超大响应不会完整进入内存
+	if transport, err := client.Transport(); err == nil {
+		client.SetTransport(&bodyTransport{base: transport, maxSize: p.Options.maxBodySize(), log: p.Options.debugLogger()})
+	}
+
+	return client
+}
+
+// fallbackProfile 生成回退使用的兼容TLS配置, 保留SNI与客户端证书设置
+// 参数:
+//   - profile: 当前TLS配置
+//
+// 返回:
+//   - *TLSProfile: 兼容TLS配置
+func fallbackProfile(profile *TLSProfile) *TLSProfile {
+	legacy := LegacyTLSProfile()
+	legacy.DisableSNI = profile.DisableSNI
+	legacy.ServerName = profile.ServerName
+	legacy.Certificates = profile.Certificates
+	return legacy
+}
+
+// isTLSHandshakeError 判断是否为TLS握手失败
+// 参数:
+//   - err: 请求错误
+//
+// 返回:
+//   - bool: 是否为握手失败
+func isTLSHandshakeError(err error) bool {
+	var alert tls.AlertError
+	if errors.As(err, &alert) {
+		return true
 	}
+	msg := err.Error()
+	return strings.Contains(msg, "tls: ") || strings.Contains(msg, "handshake")
+}
 
-	return requestHandle(resp)
+// requestHost 获取URL中的host:port, 未指定端口时按协议补全默认端口
+// 参数:
+//   - rawURL: 请求URL
+//
+// 返回:
+//   - string: host:port
+func requestHost(rawURL string) string {
+	u, err := neturl.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	if u.Port() != "" {
+		return u.Host
+	}
+	if u.Scheme == "https" {
+		return net.JoinHostPort(u.Hostname(), "443")
+	}
+	return net.JoinHostPort(u.Hostname(), "80")
 }
 
 // requestHandle 处理HTTP响应
@@ -149,8 +345,19 @@ func requestHandle(resp *resty.Response) (*HttpResponse, error) {
 	httpResp := &HttpResponse{
 		Status:     resp.Status(),
 		StatusCode: resp.StatusCode(),
-		Header:     resp.Header(),
+		Header:     restoreEncoding(resp.Header()),
 		Body:       resp.Body(),
 	}
+
+	if resp.RawResponse != nil && resp.RawResponse.Request != nil {
+		httpResp.URL = resp.RawResponse.Request.URL.String()
+	} else if resp.Request != nil {
+		httpResp.URL = resp.Request.URL
+	}
+
+	// 记录TLS对端证书链
+	if resp.RawResponse != nil && resp.RawResponse.TLS != nil {
+		httpResp.Certificates = resp.RawResponse.TLS.PeerCertificates
+	}
 	return httpResp, nil
 }
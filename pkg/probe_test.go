@@ -0,0 +1,132 @@
+package pkg
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/enenisme/definger/logger"
+)
+
+// startConnectProxy 启动仅支持CONNECT的本地代理, 记录收到的隧道请求数
+func startConnectProxy(t *testing.T, tunnels *atomic.Int32) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				req, err := http.ReadRequest(bufio.NewReader(conn))
+				if err != nil || req.Method != http.MethodConnect {
+					return
+				}
+				upstream, err := net.Dial("tcp", req.Host)
+				if err != nil {
+					return
+				}
+				defer upstream.Close()
+				tunnels.Add(1)
+				io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+				go io.Copy(upstream, conn)
+				io.Copy(conn, upstream)
+			}()
+		}
+	}()
+	return "http://" + ln.Addr().String()
+}
+
+func TestNoSNIThroughProxy(t *testing.T) {
+	var serverName atomic.Value
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	server.TLS = &tls.Config{GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		serverName.Store(hello.ServerName)
+		return nil, nil
+	}}
+	server.StartTLS()
+	defer server.Close()
+
+	var tunnels atomic.Int32
+	p := &Probes{Options: &HTTPOptions{Proxies: []string{startConnectProxy(t, &tunnels)}}}
+	profile := &TLSProfile{Name: "nosni", DisableSNI: true}
+
+	// 使用localhost访问, 未禁用SNI时会发送ServerName
+	url := fmt.Sprintf("https://localhost:%d", server.Listener.Addr().(*net.TCPAddr).Port)
+	resp, err := p.newClient(Probe{Timeout: 5}, profile, &redirectRecorder{}).R().Get(url)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", resp.String())
+	assert.Equal(t, int32(1), tunnels.Load(), "请求必须经过代理")
+	assert.Equal(t, "", serverName.Load(), "不应发送SNI")
+}
+
+func TestRedirectHopsResetOnRetry(t *testing.T) {
+	// 第一次请求跳转到返回500的页面触发重试, 重试时不再跳转
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if requests.Add(1) == 1 {
+			http.Redirect(w, r, "/broken", http.StatusFound)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	p := &Probes{Options: &HTTPOptions{}}
+	recorder := &redirectRecorder{}
+	resp, err := p.newClient(Probe{Timeout: 5}, DefaultTLSProfile(), recorder).R().Get(server.URL + "/")
+	require.NoError(t, err)
+	assert.Equal(t, "ok", resp.String())
+	assert.Empty(t, recorder.hops, "重试前的跳转不应计入结果")
+}
+
+func TestDecodedBodyKeepsEncoding(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		gz.Write(bytes.Repeat([]byte("a"), 64))
+		gz.Close()
+	}))
+	defer server.Close()
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	p := &Probes{Options: &HTTPOptions{MaxBodySize: 16, Logger: &logger.Logger{Level: logger.LogLevelDebug}}}
+	// 自行指定Accept-Encoding, 由bodyTransport而非net/http解压
+	resp, err := p.newClient(Probe{Timeout: 5}, DefaultTLSProfile(), &redirectRecorder{}).R().
+		SetHeader("Accept-Encoding", "gzip").
+		Get(server.URL)
+	require.NoError(t, err)
+
+	httpResp, err := requestHandle(resp)
+	require.NoError(t, err)
+	assert.Equal(t, bytes.Repeat([]byte("a"), 16), httpResp.Body)
+	assert.Equal(t, "gzip", httpResp.Header.Get("Content-Encoding"), "规则仍可匹配原始编码")
+	assert.Empty(t, httpResp.Header.Get(decodedEncodingHeader))
+	assert.Contains(t, buf.String(), "已截断")
+}
@@ -0,0 +1,181 @@
+package pkg
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	neturl "net/url"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// DialFunc 定义建立TCP连接的函数
+type DialFunc func(network, addr string) (net.Conn, error)
+
+// ParseProxy 校验并解析代理地址
+// 参数:
+//   - rawURL: 代理地址, 支持 http://, https://, socks5://, socks5h:// 及 user:pass@ 认证
+//
+// 返回:
+//   - *url.URL: 解析后的代理地址
+//   - error: 错误信息
+func ParseProxy(rawURL string) (*neturl.URL, error) {
+	u, err := neturl.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("代理地址无效: %v", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("不支持的代理协议: %s", rawURL)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("代理地址缺少主机: %s", rawURL)
+	}
+	return u, nil
+}
+
+// nextProxy 轮询获取下一个代理地址
+// 返回:
+//   - string: 代理地址, 未配置代理时返回空
+func (o *HTTPOptions) nextProxy() string {
+	if o == nil || len(o.Proxies) == 0 {
+		return ""
+	}
+	idx := o.proxyCounter.Add(1) - 1
+	return o.Proxies[idx%uint64(len(o.Proxies))]
+}
+
+// Dialer 获取经过代理的TCP拨号函数, 供非HTTP探测(如JARM)使用
+// 参数:
+//   - timeout: 连接超时时间
+//
+// 返回:
+//   - DialFunc: 拨号函数
+//   - error: 错误信息
+func (o *HTTPOptions) Dialer(timeout time.Duration) (DialFunc, error) {
+	dial, err := o.proxyDialer(timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	// 每次建立连接前等待全局速率与主机间隔
+	limiter := o.Limiter()
+	return func(network, addr string) (net.Conn, error) {
+		limiter.Wait(addr)
+		return dial(network, addr)
+	}, nil
+}
+
+// proxyDialer 根据代理配置生成拨号函数
+// 参数:
+//   - timeout: 连接超时时间
+//
+// 返回:
+//   - DialFunc: 拨号函数
+//   - error: 错误信息
+func (o *HTTPOptions) proxyDialer(timeout time.Duration) (DialFunc, error) {
+	return proxyDialFunc(o.nextProxy(), timeout)
+}
+
+// proxyDialFunc 生成经过指定代理的拨号函数
+// 参数:
+//   - proxyURL: 代理地址, 为空时直连
+//   - timeout: 连接超时时间
+//
+// 返回:
+//   - DialFunc: 拨号函数
+//   - error: 错误信息
+func proxyDialFunc(proxyURL string, timeout time.Duration) (DialFunc, error) {
+	direct := &net.Dialer{Timeout: timeout}
+	if proxyURL == "" {
+		return direct.Dial, nil
+	}
+
+	u, err := ParseProxy(proxyURL)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "socks5", "socks5h":
+		dialer, err := proxy.FromURL(u, direct)
+		if err != nil {
+			return nil, fmt.Errorf("创建SOCKS5代理失败: %v", err)
+		}
+		return dialer.Dial, nil
+	default:
+		return func(network, addr string) (net.Conn, error) {
+			return dialConnect(u, addr, timeout)
+		}, nil
+	}
+}
+
+// dialConnect 通过HTTP(S)代理的CONNECT方法建立隧道
+// 参数:
+//   - u: 代理地址
+//   - addr: 目标地址
+//   - timeout: 超时时间
+//
+// 返回:
+//   - net.Conn: 隧道连接
+//   - error: 错误信息
+func dialConnect(u *neturl.URL, addr string, timeout time.Duration) (net.Conn, error) {
+	proxyAddr := u.Host
+	if u.Port() == "" {
+		if u.Scheme == "https" {
+			proxyAddr = net.JoinHostPort(u.Hostname(), "443")
+		} else {
+			proxyAddr = net.JoinHostPort(u.Hostname(), "80")
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var conn net.Conn
+	var err error
+	if u.Scheme == "https" {
+		conn, err = (&tls.Dialer{Config: &tls.Config{InsecureSkipVerify: true}}).DialContext(ctx, "tcp", proxyAddr)
+	} else {
+		conn, err = (&net.Dialer{}).DialContext(ctx, "tcp", proxyAddr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("连接代理失败: %v", err)
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &neturl.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if u.User != nil {
+		password, _ := u.User.Password()
+		auth := base64.StdEncoding.EncodeToString([]byte(u.User.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+auth)
+	}
+
+	conn.SetDeadline(time.Now().Add(timeout))
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("发送CONNECT请求失败: %v", err)
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("读取CONNECT响应失败: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("代理拒绝CONNECT请求: %s", resp.Status)
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
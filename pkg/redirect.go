@@ -0,0 +1,129 @@
+package pkg
+
+import (
+	"html"
+	neturl "net/url"
+	"regexp"
+	"strings"
+)
+
+var (
+	// metaTagRegex 匹配meta标签
+	metaTagRegex = regexp.MustCompile(`(?is)<meta\b[^>]*>`)
+	// metaRefreshRegex 匹配http-equiv="refresh"
+	metaRefreshRegex = regexp.MustCompile(`(?i)http-equiv\s*=\s*["']?\s*refresh`)
+	// metaContentRegex 提取meta refresh中content的url部分
+	metaContentRegex = regexp.MustCompile(`(?is)content\s*=\s*["']?\s*\d*\s*[;,]?\s*url\s*=\s*['"]?([^"'>\s]+)`)
+	// jsLocationRegex 匹配 window.location = "..." / location.href = '...' 等赋值.
+	// 不带window等前缀的location须位于语句开头, 不能是其他对象的属性; 第1组为var/let/const声明, 命中时不是跳转
+	jsLocationRegex = regexp.MustCompile(`(?i)(?:\b(?:window|top|self|parent|document)\.location(?:\.href)?|(?:^|[^\w$.])(\b(?:var|let|const)\s+)?location(?:\.href)?)\s*=\s*["']([^"']+)["']`)
+	// jsReplaceRegex 匹配 location.replace("...") / location.assign("...")
+	jsReplaceRegex = regexp.MustCompile(`(?i)location\.(?:replace|assign)\(\s*["']([^"']+)["']\s*\)`)
+)
+
+// ClientRedirect 从页面中提取客户端跳转地址(meta refresh与简单的JavaScript location跳转)
+// 参数:
+//   - resp: 页面响应, 使用resp.URL解析相对地址
+//
+// 返回:
+//   - string: 跳转目标的绝对URL
+//   - bool: 是否存在客户端跳转
+func ClientRedirect(resp *HttpResponse) (string, bool) {
+	if resp == nil || len(resp.Body) == 0 {
+		return "", false
+	}
+	body := string(resp.Body)
+
+	target := ""
+	for _, tag := range metaTagRegex.FindAllString(body, -1) {
+		if !metaRefreshRegex.MatchString(tag) {
+			continue
+		}
+		if m := metaContentRegex.FindStringSubmatch(tag); m != nil {
+			target = m[1]
+			break
+		}
+	}
+	if target == "" {
+		target = jsLocation(body)
+	}
+	if target == "" {
+		if m := jsReplaceRegex.FindStringSubmatch(body); m != nil {
+			target = m[1]
+		}
+	}
+
+	target = strings.TrimSpace(html.UnescapeString(target))
+	if target == "" || strings.HasPrefix(target, "#") || strings.HasPrefix(strings.ToLower(target), "javascript:") {
+		return "", false
+	}
+
+	base, err := neturl.Parse(resp.URL)
+	if err != nil {
+		return "", false
+	}
+	ref, err := neturl.Parse(target)
+	if err != nil {
+		return "", false
+	}
+	abs := base.ResolveReference(ref)
+	if abs.Scheme != "http" && abs.Scheme != "https" {
+		return "", false
+	}
+	abs.Fragment = ""
+	return abs.String(), true
+}
+
+// jsLocation 提取页面中第一个JavaScript location赋值的地址, 跳过同名变量声明
+// 参数:
+//   - body: 页面内容
+//
+// 返回:
+//   - string: 跳转地址, 不存在时为空
+func jsLocation(body string) string {
+	for _, m := range jsLocationRegex.FindAllStringSubmatch(body, -1) {
+		if m[1] == "" {
+			return m[2]
+		}
+	}
+	return ""
+}
+
+// SplitURL 将绝对URL拆分为基础地址(scheme://host)与请求路径(含查询参数)
+// 参数:
+//   - rawURL: 绝对URL
+//
+// 返回:
+//   - string: 基础地址
+//   - string: 请求路径
+//   - error: 错误信息
+func SplitURL(rawURL string) (string, string, error) {
+	u, err := neturl.Parse(rawURL)
+	if err != nil {
+		return "", "", err
+	}
+	path := u.RequestURI()
+	if path == "" {
+		path = "/"
+	}
+	return u.Scheme + "://" + u.Host, path, nil
+}
+
+// RedirectProbe 基于原探针生成跟随跳转使用的GET探针, 保留原请求头
+// 参数:
+//   - probe: 原探针
+//   - path: 跳转目标路径
+//
+// 返回:
+//   - Probe: 新探针
+func RedirectProbe(probe Probe, path string) Probe {
+	lines := strings.SplitN(probe.Data, "\r\n", 2)
+	rest := "\r\n"
+	if len(lines) == 2 {
+		// 丢弃请求体, 跳转统一使用GET
+		headers := strings.SplitN(lines[1], "\r\n\r\n", 2)[0]
+		rest = "\r\n" + headers + "\r\n\r\n"
+	}
+	probe.Data = "GET " + path + " HTTP/1.1" + rest
+	return probe
+}
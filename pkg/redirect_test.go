@@ -0,0 +1,27 @@
+package pkg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientRedirect(t *testing.T) {
+	for body, want := range map[string]string{
+		`<meta http-equiv="refresh" content="0; url=/login">`:           "http://example.com/login",
+		`<script>window.location = "/portal";</script>`:                 "http://example.com/portal",
+		`<script>location.href='/index.do'</script>`:                    "http://example.com/index.do",
+		"<script>\nlocation = \"/home\"\n</script>":                     "http://example.com/home",
+		`<script>if (x) {top.location.href = "/frame"}</script>`:        "http://example.com/frame",
+		`<script>location.replace("https://sso.example.com/")</script>`: "https://sso.example.com/",
+		// 变量声明和其他对象的location属性不是跳转
+		`<script>var location = "/api"; let x = 1;</script>`:                "",
+		`<script>const location = '/api'</script>`:                          "",
+		`<script>config.location = "/static/"</script>`:                     "",
+		`<script>var location = "/api"; window.location = "/next"</script>`: "http://example.com/next",
+	} {
+		got, ok := ClientRedirect(&HttpResponse{URL: "http://example.com/", Body: []byte(body)})
+		assert.Equal(t, want, got, body)
+		assert.Equal(t, want != "", ok, body)
+	}
+}
@@ -0,0 +1,187 @@
+package pkg
+
+import "sort"
+
+// relations 定义按指纹名称汇总的推断、互斥与依赖关系, 同名规则的关系合并
+type relations struct {
+	implies  map[string][]string
+	excludes map[string][]string // 互斥关系是双向的, 两侧均记录
+	requires map[string][]string
+}
+
+// newRelations 汇总指纹规则间的关系
+// 参数:
+//   - tags: 指纹规则
+//
+// 返回:
+//   - *relations: 规则间的关系, 没有任何关系时为nil
+func newRelations(tags []Tag) *relations {
+	rel := &relations{
+		implies:  make(map[string][]string),
+		excludes: make(map[string][]string),
+		requires: make(map[string][]string),
+	}
+	add := func(m map[string][]string, from, to string) {
+		if from == "" || to == "" || from == to {
+			return
+		}
+		for _, existing := range m[from] {
+			if existing == to {
+				return
+			}
+		}
+		m[from] = append(m[from], to)
+	}
+
+	for _, tag := range tags {
+		name := tag.Info.Name
+		for _, implied := range tag.Info.Implies {
+			add(rel.implies, name, implied)
+		}
+		for _, excluded := range tag.Info.Excludes {
+			add(rel.excludes, name, excluded)
+			add(rel.excludes, excluded, name)
+		}
+		for _, required := range tag.Info.Requires {
+			add(rel.requires, name, required)
+		}
+	}
+	if len(rel.implies) == 0 && len(rel.excludes) == 0 && len(rel.requires) == 0 {
+		return nil
+	}
+	for _, excluded := range rel.excludes {
+		sort.Strings(excluded)
+	}
+	return rel
+}
+
+// Resolve 按规则间的关系整理匹配结果
+// 先按implies推断出关联指纹, 再剔除requires未满足的指纹; 存在excludes冲突时,
+// 直接匹配到的指纹优先于推断得到的指纹, 双方同为直接匹配(或同为推断)时全部剔除.
+// 剔除后重新推断, 直到结果不再变化
+// 参数:
+//   - names: 匹配到的指纹名称
+//
+// 返回:
+//   - []string: 整理后的指纹, 先按原顺序列出保留的匹配结果, 再列出推断得到的指纹
+//   - []string: 被剔除的匹配结果
+func (r *RuleSet) Resolve(names []string) ([]string, []string) {
+	if r == nil || r.relations == nil {
+		return names, nil
+	}
+	return r.relations.resolve(names)
+}
+
+// InferConfidence 补充整理后指纹的置信度, 指纹的置信度不低于推断出它的指纹
+// 参数:
+//   - resolved: Resolve整理后的指纹
+//   - confidence: 匹配到的指纹的置信度, 推断得到的指纹写入其中
+func (r *RuleSet) InferConfidence(resolved []string, confidence map[string]float64) {
+	if r == nil || r.relations == nil {
+		return
+	}
+	present := make(map[string]bool, len(resolved))
+	for _, name := range resolved {
+		present[name] = true
+	}
+	// 推断可能成链, 重复传播直到不再变化
+	for changed := true; changed; {
+		changed = false
+		for _, name := range resolved {
+			c, ok := confidence[name]
+			if !ok {
+				continue
+			}
+			for _, implied := range r.relations.implies[name] {
+				if current, ok := confidence[implied]; present[implied] && (!ok || current < c) {
+					confidence[implied] = c
+					changed = true
+				}
+			}
+		}
+	}
+}
+
+// resolve 计算关系的不动点, 说明见Resolve
+func (rel *relations) resolve(detected []string) ([]string, []string) {
+	isDetected := make(map[string]bool, len(detected))
+	for _, name := range detected {
+		isDetected[name] = true
+	}
+
+	blocked := make(map[string]bool)
+	for {
+		order, present := rel.closure(detected, blocked)
+		var block []string
+
+		// 依赖未满足的指纹不成立
+		for _, name := range order {
+			for _, required := range rel.requires[name] {
+				if !present[required] {
+					block = append(block, name)
+					break
+				}
+			}
+		}
+
+		// 依赖全部满足后再处理互斥, 同一轮的冲突同时生效, 结果与检查顺序无关
+		if len(block) == 0 {
+			for _, a := range order {
+				for _, b := range rel.excludes[a] {
+					if !present[b] || a > b {
+						continue
+					}
+					switch {
+					case isDetected[a] && !isDetected[b]:
+						block = append(block, b)
+					case isDetected[b] && !isDetected[a]:
+						block = append(block, a)
+					default:
+						block = append(block, a, b)
+					}
+				}
+			}
+		}
+
+		if len(block) == 0 {
+			var removed []string
+			for _, name := range detected {
+				if blocked[name] {
+					removed = append(removed, name)
+				}
+			}
+			return order, removed
+		}
+		for _, name := range block {
+			blocked[name] = true
+		}
+	}
+}
+
+// closure 计算匹配结果按implies推断后的全部指纹, 已剔除的指纹不参与推断
+// 参数:
+//   - detected: 匹配到的指纹名称
+//   - blocked: 已剔除的指纹
+//
+// 返回:
+//   - []string: 全部指纹, 匹配结果在前, 推断结果按推断顺序在后
+//   - map[string]bool: 全部指纹的集合
+func (rel *relations) closure(detected []string, blocked map[string]bool) ([]string, map[string]bool) {
+	present := make(map[string]bool, len(detected))
+	order := make([]string, 0, len(detected))
+	for _, name := range detected {
+		if !blocked[name] && !present[name] {
+			present[name] = true
+			order = append(order, name)
+		}
+	}
+	for i := 0; i < len(order); i++ {
+		for _, implied := range rel.implies[order[i]] {
+			if !blocked[implied] && !present[implied] {
+				present[implied] = true
+				order = append(order, implied)
+			}
+		}
+	}
+	return order, present
+}
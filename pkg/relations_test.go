@@ -0,0 +1,51 @@
+package pkg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveRelations(t *testing.T) {
+	tag := func(name string, implies, excludes, requires []string) Tag {
+		return Tag{ID: name, Info: Infos{Name: name, Implies: implies, Excludes: excludes, Requires: requires}}
+	}
+	rules := NewRuleSet([]Tag{
+		tag("Spring Boot", []string{"Java"}, nil, nil),
+		tag("Tomcat", []string{"Java"}, nil, nil),
+		tag("Nginx", nil, []string{"Apache"}, nil),
+		tag("Apache", nil, nil, nil),
+		tag("Shiro", nil, nil, []string{"Java"}),
+		tag("PHP", nil, []string{"Java"}, nil),
+	})
+
+	tests := []struct {
+		detected, resolved, removed []string
+	}{
+		// 推断关联指纹, 依赖可由推断结果满足
+		{[]string{"Spring Boot", "Shiro"}, []string{"Spring Boot", "Shiro", "Java"}, nil},
+		// 依赖未满足
+		{[]string{"Shiro", "Nginx"}, []string{"Nginx"}, []string{"Shiro"}},
+		// 同为直接匹配的互斥指纹全部剔除
+		{[]string{"Nginx", "Apache"}, []string{}, []string{"Nginx", "Apache"}},
+		// 直接匹配优先于推断, 被剔除的推断结果不再满足依赖
+		{[]string{"Tomcat", "PHP", "Shiro"}, []string{"Tomcat", "PHP"}, []string{"Shiro"}},
+	}
+	for _, tt := range tests {
+		resolved, removed := rules.Resolve(tt.detected)
+		assert.Equal(t, tt.resolved, resolved, tt.detected)
+		assert.Equal(t, tt.removed, removed, tt.detected)
+	}
+
+	// 推断得到的指纹取推断出它的指纹中的最高置信度
+	confidence := map[string]float64{"Spring Boot": 0.5, "Tomcat": 0.8}
+	resolved, _ := rules.Resolve([]string{"Spring Boot", "Tomcat"})
+	rules.InferConfidence(resolved, confidence)
+	assert.Equal(t, 0.8, confidence["Java"])
+
+	// 没有关系时原样返回
+	plain := NewRuleSet([]Tag{tag("Nginx", nil, nil, nil)})
+	resolved, removed := plain.Resolve([]string{"Nginx"})
+	assert.Equal(t, []string{"Nginx"}, resolved)
+	assert.Nil(t, removed)
+}
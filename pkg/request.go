@@ -0,0 +1,92 @@
+package pkg
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// RuleRequest 定义指纹规则自定义的请求, 扫描时与探针请求一起发送, 响应参与全部规则的匹配
+type RuleRequest struct {
+	Method  string            // 请求方法
+	Path    string            // 请求路径
+	Headers map[string]string // 请求头
+	Body    string            // 请求体
+}
+
+// ruleRequests 收集规则中首页GET以外的请求, 相同请求只保留一个
+// 参数:
+//   - tags: 指纹规则
+//
+// 返回:
+//   - []RuleRequest: 按规则顺序排列的请求
+func ruleRequests(tags []Tag) []RuleRequest {
+	var requests []RuleRequest
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		for _, block := range tag.HTTP {
+			if !block.CustomRequest() {
+				continue
+			}
+			paths := block.Path
+			if len(paths) == 0 {
+				paths = []string{"/"}
+			}
+			for _, path := range paths {
+				request := RuleRequest{Method: strings.ToUpper(block.Method), Path: path, Headers: block.Headers, Body: block.Body}
+				if request.Method == "" {
+					request.Method = http.MethodGet
+				}
+				if request.Path == "" {
+					request.Path = "/"
+				}
+				key, err := RequestKey("", request.Probe(Probe{}))
+				if err != nil || seen[key] {
+					continue
+				}
+				seen[key] = true
+				requests = append(requests, request)
+			}
+		}
+	}
+	return requests
+}
+
+// Probe 基于探针生成发送该请求的探针, 沿用探针的请求头与超时, 同名请求头以规则定义为准
+// 参数:
+//   - base: 基础探针, 通常为根路径探针
+//
+// 返回:
+//   - Probe: 新探针
+func (q RuleRequest) Probe(base Probe) Probe {
+	headers := make(map[string]string, len(q.Headers))
+	if req, err := parseProbe(base); err == nil {
+		for name, value := range req.Headers {
+			// 请求体长度由客户端计算
+			if !strings.EqualFold(name, "Content-Length") {
+				headers[http.CanonicalHeaderKey(name)] = value
+			}
+		}
+	}
+	for name, value := range q.Headers {
+		headers[http.CanonicalHeaderKey(name)] = value
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var data strings.Builder
+	data.WriteString(q.Method + " " + q.Path + " HTTP/1.1\r\n")
+	for _, name := range names {
+		data.WriteString(name + ": " + headers[name] + "\r\n")
+	}
+	data.WriteString("\r\n")
+	data.WriteString(q.Body)
+
+	base.Data = data.String()
+	base.Desc = "rule"
+	return base
+}
@@ -0,0 +1,28 @@
+package pkg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuleRequests(t *testing.T) {
+	login := HTTP{Method: "post", Path: []string{"/login"}, Headers: map[string]string{"content-type": "application/json"}, Body: "{}"}
+	tags := []Tag{
+		{ID: "a", HTTP: []HTTP{{Method: "GET", Path: []string{"/"}}, login}},
+		// 相同请求只发送一次
+		{ID: "b", HTTP: []HTTP{login, {Path: []string{"/admin", "/login"}}}},
+	}
+
+	assert.Equal(t, []RuleRequest{
+		{Method: "POST", Path: "/login", Headers: login.Headers, Body: "{}"},
+		{Method: "GET", Path: "/admin"},
+		{Method: "GET", Path: "/login"},
+	}, NewRuleSet(tags).Requests())
+
+	// 沿用基础探针的请求头与超时, 同名请求头以规则为准
+	base := Probe{Data: "GET / HTTP/1.1\r\nUser-Agent: definger\r\nContent-Type: text/html\r\nContent-Length: 0\r\n\r\n", Timeout: 7}
+	probe := RuleRequest{Method: "POST", Path: "/login", Headers: login.Headers, Body: "{}"}.Probe(base)
+	assert.Equal(t, "POST /login HTTP/1.1\r\nContent-Type: application/json\r\nUser-Agent: definger\r\n\r\n{}", probe.Data)
+	assert.Equal(t, 7, probe.Timeout)
+}
@@ -0,0 +1,406 @@
+package pkg
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// FaviconPart favicon匹配器读取的部位, 内容为以换行分隔的favicon哈希
+const FaviconPart = "favicon"
+
+// RuleSet 定义编译后的指纹规则集, 由LoadConfig在加载时生成
+// 关键字在加载时预编译, 匹配过程不再编译正则, 也不依赖全局缓存
+type RuleSet struct {
+	Tags []Tag // 原始指纹规则
+
+	rules     []compiledTag
+	relations *relations    // 规则间的推断、互斥与依赖关系
+	requests  []RuleRequest // 规则自定义的请求
+	index     *RuleIndex
+	scratch   sync.Pool // 复用预过滤的标记数组
+}
+
+// Detection 定义匹配到的指纹及其置信度
+type Detection struct {
+	Name       string
+	Confidence float64 // 置信度, 取值0到1
+}
+
+// compiledTag 定义编译后的指纹
+type compiledTag struct {
+	name      string
+	http      []compiledHTTP
+	threshold float64 // 置信度为1所需的权重之和
+	weighted  bool    // 是否设置了权重或阈值, 未设置时命中即置信度为1
+}
+
+// compiledHTTP 定义编译后的HTTP匹配块
+type compiledHTTP struct {
+	mode     string
+	matchers []compiledMatcher
+}
+
+// compiledMatcher 定义编译后的匹配器
+type compiledMatcher struct {
+	word      bool // 是否为word类型
+	favicon   bool // 是否为favicon类型, 其他类型暂不参与匹配
+	negative  bool // 是否取反
+	part      string
+	hops      bool
+	condition string
+	weight    float64
+	words     []compiledWord
+	hashes    []string // favicon哈希(小写)
+}
+
+// compiledWord 定义编译后的关键字, literal与re均为空时表示无法编译, 永不命中
+type compiledWord struct {
+	literal string         // 忽略大小写的字面量(小写)
+	re      *regexp.Regexp // 非字面量关键字的正则
+}
+
+// NewRuleSet 编译指纹规则
+// 参数:
+//   - tags: 指纹规则
+//
+// 返回:
+//   - *RuleSet: 编译后的规则集
+func NewRuleSet(tags []Tag) *RuleSet {
+	rs := &RuleSet{Tags: tags, rules: make([]compiledTag, len(tags))}
+
+	// 相同关键字只编译一次, 索引与匹配器共用
+	compiled := make(map[string]*regexp.Regexp)
+	compile := func(pattern string) (*regexp.Regexp, error) {
+		if re, ok := compiled[pattern]; ok {
+			return re, nil
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		compiled[pattern] = re
+		return re, nil
+	}
+
+	for i, tag := range tags {
+		rule := compiledTag{name: tag.Info.Name, http: make([]compiledHTTP, len(tag.HTTP)), threshold: 1}
+		if tag.Threshold > 0 {
+			rule.threshold = tag.Threshold
+			rule.weighted = true
+		}
+		for j, http := range tag.HTTP {
+			block := compiledHTTP{mode: http.Mode, matchers: make([]compiledMatcher, len(http.Matchers))}
+			for k, matcher := range http.Matchers {
+				m := compiledMatcher{
+					word:      matcher.Type == "word",
+					favicon:   matcher.Type == "favicon",
+					negative:  matcher.Negative,
+					part:      matcher.Part,
+					hops:      matcher.Hops,
+					condition: matcher.Condition,
+					weight:    1,
+				}
+				if matcher.Weight > 0 {
+					m.weight = matcher.Weight
+					rule.weighted = true
+				}
+				if m.favicon {
+					for _, hash := range matcher.Hash {
+						m.hashes = append(m.hashes, strings.ToLower(strings.TrimSpace(hash)))
+					}
+				}
+				if m.word {
+					m.words = make([]compiledWord, len(matcher.Words))
+					for w, word := range matcher.Words {
+						pattern := "(?i)(" + word + ")"
+						if literal, ok := literalOf(pattern); ok {
+							m.words[w].literal = literal
+						} else if re, err := compile(pattern); err == nil {
+							m.words[w].re = re
+						}
+					}
+				}
+				block.matchers[k] = m
+			}
+			rule.http[j] = block
+		}
+		rs.rules[i] = rule
+	}
+
+	rs.index = newRuleIndex(tags, compile)
+	rs.relations = newRelations(tags)
+	rs.requests = ruleRequests(tags)
+	rs.scratch.New = func() interface{} {
+		return rs.index.newScratch()
+	}
+	return rs
+}
+
+// Len 获取指纹规则数量
+// 返回:
+//   - int: 指纹规则数量
+func (r *RuleSet) Len() int {
+	if r == nil {
+		return 0
+	}
+	return len(r.rules)
+}
+
+// Requests 获取规则自定义的请求, 扫描时与探针请求一起发送
+// 返回:
+//   - []RuleRequest: 去重后的请求
+func (r *RuleSet) Requests() []RuleRequest {
+	if r == nil {
+		return nil
+	}
+	return r.requests
+}
+
+// Match 匹配响应, 先经索引筛选候选指纹, 再对候选指纹执行完整匹配
+// 参数:
+//   - content: 获取响应部位内容的函数
+//   - dst: 匹配结果追加到的切片
+//
+// 返回:
+//   - []string: 追加匹配到的指纹名称后的切片, 每个指纹最多出现一次
+func (r *RuleSet) Match(content ContentFunc, dst []string) []string {
+	scratch := r.scratch.Get().(*indexScratch)
+	defer r.scratch.Put(scratch)
+
+	r.index.mark(content, scratch)
+	for i, candidate := range scratch.marked {
+		if candidate && r.rules[i].match(content) {
+			dst = append(dst, r.rules[i].name)
+		}
+	}
+	return dst
+}
+
+// Detect 匹配响应并计算各指纹的置信度, 筛选方式同Match
+// 参数:
+//   - content: 获取响应部位内容的函数
+//   - dst: 匹配结果追加到的切片
+//
+// 返回:
+//   - []Detection: 追加匹配到的指纹及其置信度后的切片
+func (r *RuleSet) Detect(content ContentFunc, dst []Detection) []Detection {
+	scratch := r.scratch.Get().(*indexScratch)
+	defer r.scratch.Put(scratch)
+
+	r.index.mark(content, scratch)
+	for i, candidate := range scratch.marked {
+		if !candidate {
+			continue
+		}
+		if matched, confidence := r.rules[i].detect(content); matched {
+			dst = append(dst, Detection{Name: r.rules[i].name, Confidence: confidence})
+		}
+	}
+	return dst
+}
+
+// detect 判断指纹是否命中并计算置信度
+// 置信度为成立的匹配块中非取反匹配器的权重之和与阈值之比, 最大为1; 未设置权重与阈值时沿用match, 命中即为1.
+// 只有取反匹配器成立时权重之和为0, 视为未命中
+// 参数:
+//   - content: 获取响应部位内容的函数
+//
+// 返回:
+//   - bool: 是否命中
+//   - float64: 置信度
+func (t *compiledTag) detect(content ContentFunc) (bool, float64) {
+	if !t.weighted {
+		return t.match(content), 1
+	}
+
+	// 计算置信度需要统计全部匹配块, 不能在某个块成立时提前返回
+	score := 0.0
+	for i := range t.http {
+		if hit, weight := t.http[i].score(content); hit {
+			score += weight
+		}
+	}
+	if score <= 0 {
+		return false, 0
+	}
+	if score >= t.threshold {
+		return true, 1
+	}
+	return true, score / t.threshold
+}
+
+// match 判断指纹是否命中, 任一HTTP块成立即命中
+// 参数:
+//   - content: 获取响应部位内容的函数
+//
+// 返回:
+//   - bool: 是否命中
+func (t *compiledTag) match(content ContentFunc) bool {
+	for i := range t.http {
+		if t.http[i].hit(content) {
+			return true
+		}
+	}
+	return false
+}
+
+// hit 判断HTTP块是否成立
+// mode为and时要求块内全部匹配器成立, 否则任一匹配器成立即可
+// 参数:
+//   - content: 获取响应部位内容的函数
+//
+// 返回:
+//   - bool: 是否成立
+func (h *compiledHTTP) hit(content ContentFunc) bool {
+	if len(h.matchers) == 0 {
+		return false
+	}
+	and := h.mode == "and"
+	for i := range h.matchers {
+		if h.matchers[i].match(content) != and {
+			return !and
+		}
+	}
+	return and
+}
+
+// score 判断HTTP块是否成立并累计成立的非取反匹配器的权重, 成立条件同hit
+// 参数:
+//   - content: 获取响应部位内容的函数
+//
+// 返回:
+//   - bool: 是否成立
+//   - float64: 权重之和
+func (h *compiledHTTP) score(content ContentFunc) (bool, float64) {
+	matches := 0
+	weight := 0.0
+	for i := range h.matchers {
+		m := &h.matchers[i]
+		if !m.match(content) {
+			continue
+		}
+		matches++
+		if !m.negative {
+			weight += m.weight
+		}
+	}
+	if h.mode == "and" {
+		return matches == len(h.matchers) && matches > 0, weight
+	}
+	return matches > 0, weight
+}
+
+// match 判断匹配器是否成立
+// condition为and时要求全部关键字命中, 否则任一关键字命中即可; 没有关键字时总是成立; negative时结果取反
+// 参数:
+//   - content: 获取响应部位内容的函数
+//
+// 返回:
+//   - bool: 是否成立
+func (m *compiledMatcher) match(content ContentFunc) bool {
+	var hit bool
+	switch {
+	case m.favicon:
+		hit = m.matchFavicon(content)
+	case m.word:
+		// 响应中不存在该部位时视为未命中
+		text, ok := content(m.part, m.hops)
+		if !ok {
+			break
+		}
+		hit = len(m.words) == 0 || m.condition == "and"
+		for i := range m.words {
+			matched := m.words[i].match(text)
+			if m.condition == "and" && !matched {
+				hit = false
+				break
+			}
+			if m.condition != "and" && matched {
+				hit = true
+				break
+			}
+		}
+	default:
+		// 不支持的类型永不成立, 取反也不成立
+		return false
+	}
+	return hit != m.negative
+}
+
+// matchFavicon 判断favicon哈希是否命中, 哈希需与某一行完全一致
+// 参数:
+//   - content: 获取响应部位内容的函数
+//
+// 返回:
+//   - bool: 是否命中
+func (m *compiledMatcher) matchFavicon(content ContentFunc) bool {
+	text, ok := content(FaviconPart, false)
+	if !ok {
+		return false
+	}
+	for len(text) > 0 {
+		line := text
+		if i := strings.IndexByte(text, '\n'); i >= 0 {
+			line, text = text[:i], text[i+1:]
+		} else {
+			text = ""
+		}
+		for _, hash := range m.hashes {
+			if len(line) == len(hash) && containsFold(line, hash) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// match 判断关键字是否出现在文本中
+// 参数:
+//   - text: 目标文本
+//
+// 返回:
+//   - bool: 是否命中
+func (w *compiledWord) match(text string) bool {
+	if w.literal != "" {
+		return containsFold(text, w.literal)
+	}
+	if w.re != nil {
+		return w.re.MatchString(text)
+	}
+	return false
+}
+
+// containsFold 忽略ASCII大小写判断文本是否包含小写字面量, 不分配内存
+// 参数:
+//   - s: 目标文本
+//   - literal: 小写字面量
+//
+// 返回:
+//   - bool: 是否包含
+func containsFold(s, literal string) bool {
+	n := len(literal)
+	if n == 0 {
+		return true
+	}
+	first := literal[0]
+	upper := first
+	if 'a' <= first && first <= 'z' {
+		upper = first - ('a' - 'A')
+	}
+	for i := 0; i+n <= len(s); i++ {
+		if c := s[i]; c != first && c != upper {
+			continue
+		}
+		j := 1
+		for ; j < n; j++ {
+			if lowerASCII(s[i+j]) != literal[j] {
+				break
+			}
+		}
+		if j == n {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,165 @@
+package pkg
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Samples 定义规则的样例响应, 正例必须命中, 反例不得命中
+type Samples struct {
+	Positive []Sample `json:"positive,omitempty"`
+	Negative []Sample `json:"negative,omitempty"`
+}
+
+// Sample 定义单个样例响应, 可引用原始HTTP响应文件或直接内联响应头与响应体
+// 样例未指定favicon时favicon匹配器不命中
+type Sample struct {
+	Name        string            `json:"name,omitempty"`         // 样例名称, 用于输出
+	File        string            `json:"file,omitempty"`         // 原始HTTP响应文件, 相对路径基于规则文件所在目录
+	Status      int               `json:"status,omitempty"`       // 内联样例的状态码, 默认200
+	Headers     map[string]string `json:"headers,omitempty"`      // 内联样例的响应头
+	Body        string            `json:"body,omitempty"`         // 内联样例的响应体
+	Favicon     string            `json:"favicon,omitempty"`      // 样例的favicon哈希(MD5或mmh3)
+	FaviconFile string            `json:"favicon-file,omitempty"` // 样例的favicon图标文件, 相对路径基于规则文件所在目录
+}
+
+// Label 获取样例在输出中的名称
+// 参数:
+//   - index: 样例下标
+//
+// 返回:
+//   - string: 样例名称
+func (s *Sample) Label(index int) string {
+	switch {
+	case s.Name != "":
+		return s.Name
+	case s.File != "":
+		return s.File
+	default:
+		return fmt.Sprintf("#%d", index)
+	}
+}
+
+// empty 判断样例是否既未引用文件也未内联响应或favicon
+func (s *Sample) empty() bool {
+	return s.File == "" && s.Body == "" && len(s.Headers) == 0 && s.Favicon == "" && s.FaviconFile == ""
+}
+
+// ReadFavicon 读取样例的favicon图标文件
+// 参数:
+//   - baseDir: 解析图标文件相对路径的目录
+//
+// 返回:
+//   - []byte: 图标内容, 未指定图标文件时为空
+//   - error: 错误信息
+func (s *Sample) ReadFavicon(baseDir string) ([]byte, error) {
+	if s.FaviconFile == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(samplePath(baseDir, s.FaviconFile))
+	if err != nil {
+		return nil, fmt.Errorf("读取样例favicon失败: %v", err)
+	}
+	return data, nil
+}
+
+// samplePath 解析样例引用的文件路径
+// 参数:
+//   - baseDir: 解析相对路径的目录
+//   - path: 样例中的文件路径
+//
+// 返回:
+//   - string: 文件路径
+func samplePath(baseDir, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(baseDir, path)
+}
+
+// Response 将样例转换为HTTP响应
+// 参数:
+//   - baseDir: 解析样例文件相对路径的目录
+//
+// 返回:
+//   - *HttpResponse: 样例响应
+//   - error: 错误信息
+func (s *Sample) Response(baseDir string) (*HttpResponse, error) {
+	if s.File != "" {
+		data, err := os.ReadFile(samplePath(baseDir, s.File))
+		if err != nil {
+			return nil, fmt.Errorf("读取样例文件失败: %v", err)
+		}
+		return ParseRawResponse(data)
+	}
+
+	status := s.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	header := make(http.Header)
+	for key, value := range s.Headers {
+		header.Set(key, value)
+	}
+	return &HttpResponse{
+		Status:     fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode: status,
+		Header:     header,
+		Body:       []byte(s.Body),
+	}, nil
+}
+
+// ParseRawResponse 解析原始HTTP响应报文, 按Content-Encoding解压响应体
+// 参数:
+//   - data: 原始响应报文, 兼容仅使用\n换行的文件
+//
+// 返回:
+//   - *HttpResponse: 解析后的响应
+//   - error: 错误信息
+func ParseRawResponse(data []byte) (*HttpResponse, error) {
+	head, body := data, []byte(nil)
+	if idx := bytes.Index(data, []byte("\r\n\r\n")); idx >= 0 {
+		head, body = data[:idx], data[idx+4:]
+	} else if idx := bytes.Index(data, []byte("\n\n")); idx >= 0 {
+		head, body = data[:idx], data[idx+2:]
+	}
+
+	// 响应头统一为\r\n换行; 手工编辑的样例中Content-Length常与实际不符, 忽略后读取至文件末尾
+	var raw bytes.Buffer
+	for _, line := range strings.Split(strings.ReplaceAll(string(head), "\r\n", "\n"), "\n") {
+		if strings.HasPrefix(strings.ToLower(line), "content-length:") {
+			continue
+		}
+		raw.WriteString(line)
+		raw.WriteString("\r\n")
+	}
+	raw.WriteString("\r\n")
+	raw.Write(body)
+
+	resp, err := http.ReadResponse(bufio.NewReader(&raw), nil)
+	if err != nil {
+		return nil, fmt.Errorf("解析原始响应失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	decoded, err := decodeBody(resp.Body, resp.Header.Get("Content-Encoding"))
+	if err != nil {
+		return nil, err
+	}
+	content, err := io.ReadAll(decoded)
+	if err != nil {
+		return nil, fmt.Errorf("读取样例响应体失败: %v", err)
+	}
+	return &HttpResponse{
+		Status:     resp.Status,
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       content,
+	}, nil
+}
@@ -1,14 +1,19 @@
 package pkg
 
+import "strings"
+
 type Tags struct {
 	Tags []Tag
 }
 
 // Tag 定义指纹的结构
 type Tag struct {
-	ID   string `json:"id"`
-	Info Infos  `json:"info"`
-	HTTP []HTTP `json:"http"`
+	ID        string   `json:"id"`
+	Info      Infos    `json:"info"`
+	HTTP      []HTTP   `json:"http"`
+	Threshold float64  `json:"threshold,omitempty"` // 置信度为1所需的匹配器权重之和, 默认为1
+	Samples   *Samples `json:"samples,omitempty"`   // 规则测试使用的样例响应
+	Source    string   `json:"-"`                   // 规则所在文件, 加载时填充
 }
 
 // Infos 定义指纹的信息
@@ -18,6 +23,9 @@ type Infos struct {
 	Tags     string    `json:"tags"`
 	Severity string    `json:"severity"`
 	Metadata Metadatas `json:"metadata"`
+	Implies  []string  `json:"implies,omitempty"`  // 命中时可推断存在的其他指纹
+	Excludes []string  `json:"excludes,omitempty"` // 不可能与之同时存在的指纹
+	Requires []string  `json:"requires,omitempty"` // 需同时存在才成立的指纹
 }
 
 // Metadatas 定义指纹的元数据
@@ -29,10 +37,27 @@ type Metadatas struct {
 
 // HTTP 定义指纹的HTTP请求
 type HTTP struct {
-	Method   string     `json:"method"`
-	Path     []string   `json:"path"`
-	Mode     string     `json:"mode,omitempty"`
-	Matchers []Matchers `json:"matchers"`
+	Method   string            `json:"method"`
+	Path     []string          `json:"path"`
+	Headers  map[string]string `json:"headers,omitempty"` // 请求头
+	Body     string            `json:"body,omitempty"`    // 请求体
+	Mode     string            `json:"mode,omitempty"`
+	Matchers []Matchers        `json:"matchers"`
+}
+
+// CustomRequest 判断匹配块是否定义了首页GET以外的请求, 这类请求在扫描时与探针请求一起发送
+// 返回:
+//   - bool: 是否为自定义请求
+func (h HTTP) CustomRequest() bool {
+	if len(h.Headers) > 0 || h.Body != "" || (h.Method != "" && !strings.EqualFold(h.Method, "GET")) {
+		return true
+	}
+	for _, path := range h.Path {
+		if path != "" && path != "/" {
+			return true
+		}
+	}
+	return false
 }
 
 // Matchers 定义指纹的匹配器
@@ -43,4 +68,7 @@ type Matchers struct {
 	Condition       string   `json:"condition,omitempty"`
 	CaseInsensitive bool     `json:"case-insensitive,omitempty"`
 	Hash            []string `json:"hash,omitempty"`
+	Hops            bool     `json:"hops,omitempty"`     // 是否同时匹配服务端跳转的中间响应
+	Negative        bool     `json:"negative,omitempty"` // 是否取反, 关键字未命中时匹配器成立
+	Weight          float64  `json:"weight,omitempty"`   // 成立时计入置信度的权重, 默认为1, 取反的匹配器不计入
 }
@@ -0,0 +1,286 @@
+package pkg
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// 校验问题级别
+const (
+	IssueError   = "error"   // 规则无法按预期工作
+	IssueWarning = "warning" // 规则可用但可能产生误报
+)
+
+// MatchParts 匹配器支持的匹配部位, 与match.buildParts生成的部位保持一致
+var MatchParts = map[string]struct{}{
+	"header":       {},
+	"body":         {},
+	"title":        {},
+	"cert.subject": {},
+	"cert.issuer":  {},
+	"cert.san":     {},
+	"cert.serial":  {},
+	"cert.sha256":  {},
+	"tls.jarm":     {},
+}
+
+// genericWords 常见于各类页面、单独使用时容易误报的关键字
+var genericWords = map[string]struct{}{
+	"admin": {}, "login": {}, "index": {}, "html": {}, "http": {}, "https": {},
+	"title": {}, "script": {}, "jquery": {}, "server": {}, "error": {}, "welcome": {},
+	"password": {}, "username": {}, "copyright": {}, "powered by": {}, "content-type": {},
+	"text/html": {}, "nginx": {}, "apache": {}, "javascript": {}, "css": {}, "api": {},
+}
+
+// Issue 定义规则校验发现的问题
+type Issue struct {
+	ID      string // 规则ID
+	File    string // 规则所在文件
+	Path    string // 规则在所在文件中的JSON路径, 例如 $[0].http[0].matchers[1].words[2]
+	Level   string // 问题级别
+	Message string // 问题描述
+}
+
+// String 获取问题的文本描述
+// 返回:
+//   - string: 问题描述
+func (i Issue) String() string {
+	id := i.ID
+	if id == "" {
+		id = "-"
+	}
+	return fmt.Sprintf("%s %s %s: %s", strings.ToUpper(i.Level), id, i.Path, i.Message)
+}
+
+// ValidateOptions 定义规则校验选项
+type ValidateOptions struct {
+	GenericWords bool // 是否对过于宽泛的关键字给出警告
+}
+
+// ValidateTags 校验指纹规则, 返回全部问题
+// 参数:
+//   - tags: 指纹规则
+//   - options: 校验选项
+//
+// 返回:
+//   - []Issue: 发现的问题, 按规则顺序排列
+func ValidateTags(tags []Tag, options ValidateOptions) []Issue {
+	var issues []Issue
+	seen := make(map[string]string)
+	offsets := make(map[string]int) // 各文件的规则计数, 用于生成文件内的路径
+
+	// 规则定义或推断得到的指纹名称, 互斥与依赖关系只能引用其中的指纹
+	known := make(map[string]bool)
+	for _, tag := range tags {
+		known[tag.Info.Name] = true
+		for _, implied := range tag.Info.Implies {
+			known[implied] = true
+		}
+	}
+
+	for _, tag := range tags {
+		root := fmt.Sprintf("$[%d]", offsets[tag.Source])
+		offsets[tag.Source]++
+		report := func(level, path, format string, args ...interface{}) {
+			issues = append(issues, Issue{ID: tag.ID, File: tag.Source, Path: path, Level: level, Message: fmt.Sprintf(format, args...)})
+		}
+
+		if strings.TrimSpace(tag.ID) == "" {
+			report(IssueError, root+".id", "缺少规则ID")
+		} else if first, ok := seen[tag.ID]; ok {
+			report(IssueError, root+".id", "规则ID与%s重复", first)
+		} else {
+			seen[tag.ID] = strings.TrimPrefix(tag.Source+" "+root, " ")
+		}
+		if strings.TrimSpace(tag.Info.Name) == "" {
+			report(IssueError, root+".info.name", "缺少指纹名称")
+		}
+		if len(tag.HTTP) == 0 {
+			report(IssueError, root+".http", "未定义HTTP匹配块")
+		}
+		validateRelations(tag.Info, root+".info", known, report)
+		validateWeights(tag, root, report)
+
+		if tag.Samples != nil {
+			for j, sample := range tag.Samples.Positive {
+				if sample.empty() {
+					report(IssueError, fmt.Sprintf("%s.samples.positive[%d]", root, j), "样例未指定文件、内联响应或favicon")
+				}
+			}
+			for j, sample := range tag.Samples.Negative {
+				if sample.empty() {
+					report(IssueError, fmt.Sprintf("%s.samples.negative[%d]", root, j), "样例未指定文件、内联响应或favicon")
+				}
+			}
+		}
+
+		for j, http := range tag.HTTP {
+			block := fmt.Sprintf("%s.http[%d]", root, j)
+			if !validCondition(http.Mode) {
+				report(IssueError, block+".mode", "未知的匹配模式: %q", http.Mode)
+			}
+			if len(http.Matchers) == 0 {
+				report(IssueError, block+".matchers", "未定义匹配器")
+			}
+
+			for k, matcher := range http.Matchers {
+				path := fmt.Sprintf("%s.matchers[%d]", block, k)
+				switch matcher.Type {
+				case "word":
+				case "favicon":
+					if len(matcher.Hash) == 0 {
+						report(IssueError, path+".hash", "favicon匹配器未指定哈希")
+					}
+					continue
+				default:
+					report(IssueError, path+".type", "不支持的匹配器类型: %q", matcher.Type)
+					continue
+				}
+				if _, ok := MatchParts[matcher.Part]; !ok {
+					report(IssueError, path+".part", "未知的匹配部位: %q", matcher.Part)
+				}
+				if !validCondition(matcher.Condition) {
+					report(IssueError, path+".condition", "未知的匹配条件: %q", matcher.Condition)
+				}
+				if len(matcher.Words) == 0 {
+					report(IssueError, path+".words", "关键字列表为空, 匹配器总是成立")
+				}
+
+				for w, word := range matcher.Words {
+					wordPath := fmt.Sprintf("%s.words[%d]", path, w)
+					if strings.TrimSpace(word) == "" {
+						report(IssueError, wordPath, "关键字为空, 匹配任意内容")
+						continue
+					}
+					pattern := "(?i)(" + word + ")"
+					if _, err := regexp.Compile(pattern); err != nil {
+						report(IssueError, wordPath, "关键字不是合法的正则表达式: %v", err)
+						continue
+					}
+					if options.GenericWords && genericWord(word, matcher.Condition, len(matcher.Words)) {
+						report(IssueWarning, wordPath, "关键字 %q 过于宽泛, 可能产生误报", word)
+					}
+				}
+			}
+		}
+	}
+	return issues
+}
+
+// validateWeights 校验匹配器权重与规则阈值
+// 参数:
+//   - tag: 指纹规则
+//   - root: 规则的路径
+//   - report: 记录问题的函数
+func validateWeights(tag Tag, root string, report func(level, path, format string, args ...interface{})) {
+	if tag.Threshold < 0 {
+		report(IssueError, root+".threshold", "阈值不能为负数")
+	}
+
+	total := 0.0
+	for j, http := range tag.HTTP {
+		for k, matcher := range http.Matchers {
+			path := fmt.Sprintf("%s.http[%d].matchers[%d].weight", root, j, k)
+			switch {
+			case matcher.Weight < 0:
+				report(IssueError, path, "权重不能为负数")
+			case matcher.Negative && matcher.Weight > 0:
+				report(IssueWarning, path, "取反的匹配器不计入置信度, 权重无效")
+			case matcher.Negative:
+			case matcher.Weight > 0:
+				total += matcher.Weight
+			default:
+				total++
+			}
+		}
+	}
+	if tag.Threshold > 0 && total < tag.Threshold {
+		report(IssueWarning, root+".threshold", "全部匹配器的权重之和 %g 低于阈值 %g, 置信度无法达到1", total, tag.Threshold)
+	}
+}
+
+// validateRelations 校验指纹的推断、互斥与依赖关系
+// 参数:
+//   - info: 指纹信息
+//   - root: info字段的路径
+//   - known: 规则定义或推断得到的指纹名称
+//   - report: 记录问题的函数
+func validateRelations(info Infos, root string, known map[string]bool, report func(level, path, format string, args ...interface{})) {
+	related := make(map[string]string)
+	check := func(field string, names []string) {
+		for i, name := range names {
+			path := fmt.Sprintf("%s.%s[%d]", root, field, i)
+			switch {
+			case strings.TrimSpace(name) == "":
+				report(IssueError, path, "指纹名称为空")
+				continue
+			case name == info.Name:
+				report(IssueWarning, path, "引用了自身, 将被忽略")
+				continue
+			case field == "requires" && !known[name]:
+				report(IssueWarning, path, "指纹 %q 未由任何规则定义或推断, 本规则永远不会成立", name)
+			case field == "excludes" && !known[name]:
+				report(IssueWarning, path, "指纹 %q 未由任何规则定义或推断, 互斥关系不会生效", name)
+			}
+			if other, ok := related[name]; ok && (other == "excludes") != (field == "excludes") {
+				report(IssueError, path, "指纹 %q 同时出现在%s与%s中", name, other, field)
+			}
+			related[name] = field
+		}
+	}
+	check("implies", info.Implies)
+	check("requires", info.Requires)
+	check("excludes", info.Excludes)
+}
+
+// HasErrors 判断问题列表中是否存在错误级别的问题
+// 参数:
+//   - issues: 问题列表
+//
+// 返回:
+//   - bool: 是否存在错误
+func HasErrors(issues []Issue) bool {
+	for _, issue := range issues {
+		if issue.Level == IssueError {
+			return true
+		}
+	}
+	return false
+}
+
+// validCondition 判断匹配条件/模式是否合法
+func validCondition(condition string) bool {
+	return condition == "" || condition == "or" || condition == "and"
+}
+
+// genericWord 判断关键字是否过于宽泛
+// and条件下关键字需同时命中, 仅当匹配器只有这一个关键字时才视为宽泛
+// 参数:
+//   - word: 关键字
+//   - condition: 匹配条件
+//   - count: 匹配器中关键字数量
+//
+// 返回:
+//   - bool: 是否过于宽泛
+func genericWord(word, condition string, count int) bool {
+	if condition == "and" && count > 1 {
+		return false
+	}
+	literal, ok := literalOf("(?i)(" + word + ")")
+	if !ok {
+		// 能匹配极短内容的正则同样宽泛, 例如 .* 或 \w+
+		return !hasLongLiteral(word)
+	}
+	if _, ok := genericWords[literal]; ok {
+		return true
+	}
+	// 按字节计算, 中文产品名即使只有两个字也不视为宽泛
+	return len(literal) < 4
+}
+
+// hasLongLiteral 判断正则是否包含足够长的必需字面量
+func hasLongLiteral(word string) bool {
+	literal, ok := requiredLiteral("(?i)(" + word + ")")
+	return ok && len(literal) >= 4
+}
@@ -0,0 +1,35 @@
+package pkg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateTags(t *testing.T) {
+	tags := []Tag{
+		{ID: "a", Info: Infos{Name: "A"}, HTTP: []HTTP{{Matchers: []Matchers{{Type: "word", Part: "body", Words: []string{"acme portal"}}}}}},
+		{ID: "a", Info: Infos{Name: "B", Implies: []string{"Java"}, Requires: []string{"A", "Go"}, Excludes: []string{"Java"}}, Threshold: 3, HTTP: []HTTP{{Matchers: []Matchers{
+			{Type: "word", Part: "cookie", Words: []string{"x("}},
+			{Type: "word", Part: "body", Words: []string{"admin"}, Weight: -1},
+		}}}},
+	}
+
+	issues := ValidateTags(tags, ValidateOptions{GenericWords: true})
+	paths := make(map[string]string)
+	for _, issue := range issues {
+		paths[issue.Path] = issue.Level
+	}
+	assert.Equal(t, map[string]string{
+		"$[1].id":                           IssueError,
+		"$[1].info.requires[1]":             IssueWarning,
+		"$[1].info.excludes[0]":             IssueError,
+		"$[1].threshold":                    IssueWarning,
+		"$[1].http[0].matchers[1].weight":   IssueError,
+		"$[1].http[0].matchers[0].part":     IssueError,
+		"$[1].http[0].matchers[0].words[0]": IssueError,
+		"$[1].http[0].matchers[1].words[0]": IssueWarning,
+	}, paths)
+	assert.True(t, HasErrors(issues))
+	assert.Empty(t, ValidateTags(tags[:1], ValidateOptions{GenericWords: true}))
+}
@@ -0,0 +1,15 @@
+// Package rules 提供编译进程序的默认指纹规则包, 未指定规则文件时使用
+package rules
+
+import _ "embed"
+
+// Version 内置规则包的版本, 修改default.json时同步更新
+const Version = "1.0.1"
+
+// File 内置规则包的文件名, 作为加载后规则的来源文件
+const File = "default.json"
+
+// Default 内置规则包的内容, 格式与指纹规则(JSON)文件相同
+//
+//go:embed default.json
+var Default []byte
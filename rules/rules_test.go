@@ -0,0 +1,33 @@
+package rules_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/enenisme/definger/logger"
+	"github.com/enenisme/definger/match"
+	"github.com/enenisme/definger/pkg"
+	"github.com/enenisme/definger/rules"
+)
+
+func TestDefaultRules(t *testing.T) {
+	var tags []pkg.Tag
+	require.NoError(t, json.Unmarshal(rules.Default, &tags))
+	require.NotEmpty(t, tags)
+	assert.Empty(t, pkg.ValidateTags(tags, pkg.ValidateOptions{GenericWords: true}))
+
+	// 每条内置规则至少有一个正例, 全部样例必须通过
+	log := &logger.Logger{Level: logger.LogLevelError}
+	for _, tag := range tags {
+		if !assert.NotNil(t, tag.Samples, tag.ID) || !assert.NotEmpty(t, tag.Samples.Positive, tag.ID) {
+			continue
+		}
+		for _, result := range match.RunSamples(tag, "", log) {
+			assert.NoError(t, result.Err, "%s %s", tag.ID, result.Sample)
+			assert.True(t, result.Passed, "%s %s", tag.ID, result.Sample)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package utils
+
+import (
+	"fmt"
+	"regexp/syntax"
+	"strings"
+
+	"github.com/enenisme/definger/pkg"
+)
+
+// ExportFormats 支持导出的指纹库格式
+var ExportFormats = []string{"nuclei", "ehole", "wappalyzer"}
+
+// ExportedFile 定义导出的单个文件
+type ExportedFile struct {
+	Name string // 建议的文件名
+	Data []byte // 文件内容
+}
+
+// ExportRules 将指纹规则转换为其他工具的指纹库格式, 无法表示的匹配器以警告形式返回
+// nuclei每条规则导出为一个模板文件, EHole与Wappalyzer导出为单个文件
+// 参数:
+//   - format: 目标格式, 取值见ExportFormats
+//   - tags: 指纹规则
+//
+// 返回值:
+//   - []ExportedFile: 导出的文件
+//   - []pkg.Issue: 无法完整导出的警告, Path为规则中的位置
+//   - error: 错误信息
+func ExportRules(format string, tags []pkg.Tag) ([]ExportedFile, []pkg.Issue, error) {
+	ex := &ruleExporter{}
+	var (
+		files []ExportedFile
+		err   error
+	)
+	switch strings.ToLower(format) {
+	case "nuclei":
+		files, err = ex.nuclei(tags)
+	case "ehole":
+		files, err = ex.ehole(tags)
+	case "wappalyzer":
+		files, err = ex.wappalyzer(tags)
+	default:
+		return nil, nil, fmt.Errorf("不支持的导出格式: %s, 可选: %s", format, strings.Join(ExportFormats, ", "))
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return files, ex.warnings, nil
+}
+
+// ruleExporter 定义导出过程的公共状态
+type ruleExporter struct {
+	warnings []pkg.Issue
+}
+
+// warn 记录导出警告
+func (ex *ruleExporter) warn(tag pkg.Tag, path, format string, args ...interface{}) {
+	ex.warnings = append(ex.warnings, pkg.Issue{
+		ID:      tag.ID,
+		File:    tag.Source,
+		Path:    path,
+		Level:   pkg.IssueWarning,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// exportGroup 定义导出时的匹配组, or组任一匹配器成立即命中, and组要求全部匹配器成立
+type exportGroup struct {
+	and      bool
+	http     pkg.HTTP // 请求信息, 取自匹配器所在的第一个匹配块
+	mixed    bool     // and组的匹配器来自请求不同的多个匹配块
+	matchers []exportMatcher
+}
+
+// exportMatcher 定义匹配组中的匹配器
+type exportMatcher struct {
+	pkg.Matchers
+	path string // 匹配器在规则中的位置
+}
+
+// groups 按引擎的匹配语义整理规则的匹配块
+// 任一or模式匹配块中的匹配器成立即命中, 此时and模式匹配块不会生效;
+// 只有and模式匹配块时要求所有匹配块中的全部匹配器成立
+// 参数:
+//   - tag: 指纹规则
+//
+// 返回值:
+//   - []exportGroup: 匹配组, 存在or模式匹配块时每块一组, 否则只有一个and组; 没有匹配器时为空
+func (ex *ruleExporter) groups(tag pkg.Tag) []exportGroup {
+	var groups []exportGroup
+	var and *exportGroup
+	for i, http := range tag.HTTP {
+		matchers := make([]exportMatcher, 0, len(http.Matchers))
+		for j, matcher := range http.Matchers {
+			matchers = append(matchers, exportMatcher{Matchers: matcher, path: fmt.Sprintf("http[%d].matchers[%d]", i, j)})
+		}
+		if len(matchers) == 0 {
+			continue
+		}
+		if http.Mode != "and" {
+			groups = append(groups, exportGroup{http: http, matchers: matchers})
+			continue
+		}
+		if and == nil {
+			and = &exportGroup{and: true, http: http}
+		} else if !sameRequest(and.http, http) {
+			and.mixed = true
+		}
+		and.matchers = append(and.matchers, matchers...)
+	}
+
+	if and != nil {
+		if len(groups) > 0 {
+			ex.warn(tag, "http", "and模式匹配块与or模式匹配块共存时不会生效, 已忽略")
+		} else {
+			groups = append(groups, *and)
+		}
+	}
+	return groups
+}
+
+// checkRequest 检查只匹配首页响应的格式能否表示规则的请求, 无法表示时记录警告
+// 参数:
+//   - tag: 指纹规则
+//   - format: 目标格式名称
+func (ex *ruleExporter) checkRequest(tag pkg.Tag, format string) {
+	for i, http := range tag.HTTP {
+		if http.CustomRequest() {
+			ex.warn(tag, fmt.Sprintf("http[%d]", i), "%s只匹配首页响应, 自定义请求已忽略", format)
+		}
+	}
+}
+
+// sameRequest 判断两个匹配块的请求是否相同
+func sameRequest(a, b pkg.HTTP) bool {
+	if !strings.EqualFold(a.Method, b.Method) || a.Body != b.Body ||
+		strings.Join(a.Path, "\n") != strings.Join(b.Path, "\n") || len(a.Headers) != len(b.Headers) {
+		return false
+	}
+	for name, value := range a.Headers {
+		if b.Headers[name] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// literalWord 判断关键字正则是否为字面量
+// 参数:
+//   - pattern: 关键字正则
+//
+// 返回值:
+//   - string: 字面量
+//   - bool: 是否为字面量
+func literalWord(pattern string) (string, bool) {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return "", false
+	}
+	re = re.Simplify()
+	if re.Op != syntax.OpLiteral || len(re.Rune) == 0 {
+		return "", false
+	}
+	return string(re.Rune), true
+}
+
+// literalWords 在全部关键字均为字面量时返回字面量列表
+// 参数:
+//   - patterns: 关键字正则
+//
+// 返回值:
+//   - []string: 字面量
+//   - bool: 是否全部为非空字面量
+func literalWords(patterns []string) ([]string, bool) {
+	literals := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		literal, ok := literalWord(pattern)
+		if !ok {
+			return nil, false
+		}
+		literals = append(literals, literal)
+	}
+	return literals, true
+}
+
+// isMMH3Hash 判断favicon哈希是否为mmh3(有符号整数), 否则视为MD5
+func isMMH3Hash(hash string) bool {
+	hash = strings.TrimPrefix(strings.TrimSpace(hash), "-")
+	if hash == "" {
+		return false
+	}
+	for _, r := range hash {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,190 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"github.com/enenisme/definger/pkg"
+)
+
+// eholeExportLocations 本项目匹配部位到EHole匹配位置的映射
+var eholeExportLocations = map[string]string{
+	"body":   "body",
+	"header": "header",
+	"title":  "title",
+}
+
+// ehole 将指纹规则导出为EHole的finger.json
+// EHole单条指纹的关键字之间为and关系, 多条指纹之间为or关系, 规则按此展开为多条指纹
+// 参数:
+//   - tags: 指纹规则
+//
+// 返回值:
+//   - []ExportedFile: finger.json
+//   - error: 错误信息
+func (ex *ruleExporter) ehole(tags []pkg.Tag) ([]ExportedFile, error) {
+	finger := eholeFinger{Fingerprint: []eholeRule{}}
+	for _, tag := range tags {
+		ex.checkRequest(tag, "EHole")
+		cms := tag.Info.Name
+		if cms == "" {
+			cms = tag.ID
+		}
+
+		var rules []eholeRule
+		for _, group := range ex.groups(tag) {
+			if group.and {
+				rules = append(rules, ex.eholeAnd(tag, cms, group)...)
+				continue
+			}
+			for _, m := range group.matchers {
+				rules = append(rules, ex.eholeRules(tag, cms, m)...)
+			}
+		}
+		if len(rules) == 0 {
+			ex.warn(tag, "http", "没有可导出为EHole的匹配器, 已跳过")
+			continue
+		}
+		finger.Fingerprint = append(finger.Fingerprint, rules...)
+	}
+
+	data, err := json.MarshalIndent(finger, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("序列化EHole指纹失败: %v", err)
+	}
+	return []ExportedFile{{Name: "finger.json", Data: data}}, nil
+}
+
+// eholeAnd 展开and组, 各匹配器的可选指纹两两合并, 只能合并同一位置的关键字
+// 参数:
+//   - tag: 指纹规则
+//   - cms: 指纹名称
+//   - group: and组
+//
+// 返回值:
+//   - []eholeRule: EHole指纹, 无法表示时为空
+func (ex *ruleExporter) eholeAnd(tag pkg.Tag, cms string, group exportGroup) []eholeRule {
+	var rules []eholeRule
+	for _, m := range group.matchers {
+		alternatives := ex.eholeRules(tag, cms, m)
+		if len(alternatives) == 0 {
+			continue
+		}
+		if rules == nil {
+			rules = alternatives
+			continue
+		}
+
+		merged := make([]eholeRule, 0, len(rules)*len(alternatives))
+		for _, rule := range rules {
+			for _, alternative := range alternatives {
+				combined, ok := mergeEholeRules(rule, alternative)
+				if !ok {
+					ex.warn(tag, m.path, "EHole无法表示不同位置或favicon的and条件, 已跳过")
+					return nil
+				}
+				merged = append(merged, combined)
+			}
+		}
+		if len(merged) > maxDNFTerms {
+			ex.warn(tag, m.path, "展开后的指纹超过 %d 条, 已跳过", maxDNFTerms)
+			return nil
+		}
+		rules = merged
+	}
+	return rules
+}
+
+// eholeRules 将单个匹配器转换为可选的EHole指纹, 任一指纹命中即匹配器成立
+// EHole的keyword区分大小写, 字面量关键字仍导出为keyword以保持可读性; 正则导出为regular并忽略大小写
+// 参数:
+//   - tag: 指纹规则
+//   - cms: 指纹名称
+//   - m: 匹配器
+//
+// 返回值:
+//   - []eholeRule: EHole指纹, 无法表示时为空
+func (ex *ruleExporter) eholeRules(tag pkg.Tag, cms string, m exportMatcher) []eholeRule {
+	if m.Negative {
+		ex.warn(tag, m.path+".negative", "EHole不支持取反匹配, 已忽略")
+		return nil
+	}
+
+	switch m.Type {
+	case "favicon":
+		var rules []eholeRule
+		for _, hash := range m.Hash {
+			if !isMMH3Hash(hash) {
+				ex.warn(tag, m.path+".hash", "EHole只支持mmh3哈希, 已忽略 %s", hash)
+				continue
+			}
+			rules = append(rules, eholeRule{CMS: cms, Method: "faviconhash", Location: "body", Keyword: []string{hash}})
+		}
+		return rules
+	case "word":
+	default:
+		ex.warn(tag, m.path, "不支持的匹配器类型 %s, 已忽略", m.Type)
+		return nil
+	}
+
+	location, ok := eholeExportLocations[m.Part]
+	if !ok {
+		ex.warn(tag, m.path+".part", "EHole不支持匹配部位 %s, 已忽略", m.Part)
+		return nil
+	}
+	if len(m.Words) == 0 {
+		ex.warn(tag, m.path+".words", "没有关键字, 已忽略")
+		return nil
+	}
+	if m.Hops {
+		ex.warn(tag, m.path+".hops", "EHole只匹配最终响应, 跳转中间响应的匹配已忽略")
+	}
+
+	rule := eholeRule{CMS: cms, Location: location}
+	if literals, ok := literalWords(m.Words); ok {
+		rule.Method, rule.Keyword = "keyword", literals
+	} else {
+		rule.Method = "regular"
+		for _, word := range m.Words {
+			rule.Keyword = append(rule.Keyword, "(?i)"+word)
+		}
+	}
+
+	if m.Condition == "and" || len(rule.Keyword) == 1 {
+		return []eholeRule{rule}
+	}
+	rules := make([]eholeRule, 0, len(rule.Keyword))
+	for _, keyword := range rule.Keyword {
+		rules = append(rules, eholeRule{CMS: cms, Method: rule.Method, Location: location, Keyword: []string{keyword}})
+	}
+	return rules
+}
+
+// mergeEholeRules 合并同一位置的两条指纹, 关键字取并集(and关系)
+// 参数:
+//   - a: 指纹
+//   - b: 指纹
+//
+// 返回值:
+//   - eholeRule: 合并后的指纹, 任一为正则时统一为正则
+//   - bool: 是否可以合并
+func mergeEholeRules(a, b eholeRule) (eholeRule, bool) {
+	if a.Location != b.Location || a.Method == "faviconhash" || b.Method == "faviconhash" {
+		return eholeRule{}, false
+	}
+	if a.Method == b.Method {
+		return eholeRule{CMS: a.CMS, Method: a.Method, Location: a.Location, Keyword: append(append([]string{}, a.Keyword...), b.Keyword...)}, true
+	}
+
+	merged := eholeRule{CMS: a.CMS, Method: "regular", Location: a.Location}
+	for _, rule := range []eholeRule{a, b} {
+		for _, keyword := range rule.Keyword {
+			if rule.Method == "keyword" {
+				keyword = "(?i)" + regexp.QuoteMeta(keyword)
+			}
+			merged.Keyword = append(merged.Keyword, keyword)
+		}
+	}
+	return merged, true
+}
@@ -0,0 +1,276 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/enenisme/definger/pkg"
+)
+
+// nucleiExportTemplate 定义导出的nuclei模板
+type nucleiExportTemplate struct {
+	ID   string                `yaml:"id"`
+	Info nucleiExportInfo      `yaml:"info"`
+	HTTP []nucleiExportRequest `yaml:"http"`
+}
+
+// nucleiExportInfo 定义导出的nuclei模板info字段
+type nucleiExportInfo struct {
+	Name     string                 `yaml:"name"`
+	Author   string                 `yaml:"author"`
+	Severity string                 `yaml:"severity"`
+	Tags     string                 `yaml:"tags,omitempty"`
+	Metadata map[string]interface{} `yaml:"metadata,omitempty"`
+}
+
+// nucleiExportRequest 定义导出的nuclei HTTP请求
+type nucleiExportRequest struct {
+	Method            string                `yaml:"method"`
+	Path              []string              `yaml:"path"`
+	Headers           map[string]string     `yaml:"headers,omitempty"`
+	Body              string                `yaml:"body,omitempty"`
+	Redirects         bool                  `yaml:"redirects,omitempty"`
+	MaxRedirects      int                   `yaml:"max-redirects,omitempty"`
+	MatchersCondition string                `yaml:"matchers-condition,omitempty"`
+	Matchers          []nucleiExportMatcher `yaml:"matchers"`
+}
+
+// nucleiExportMatcher 定义导出的nuclei匹配器
+type nucleiExportMatcher struct {
+	Type            string   `yaml:"type"`
+	Part            string   `yaml:"part,omitempty"`
+	Words           []string `yaml:"words,omitempty"`
+	Regex           []string `yaml:"regex,omitempty"`
+	DSL             []string `yaml:"dsl,omitempty"`
+	Condition       string   `yaml:"condition,omitempty"`
+	CaseInsensitive bool     `yaml:"case-insensitive,omitempty"`
+	Negative        bool     `yaml:"negative,omitempty"`
+}
+
+// nucleiExportParts 本项目匹配部位到nuclei匹配部位的映射
+var nucleiExportParts = map[string]string{
+	"body":   "body",
+	"header": "header",
+}
+
+// nucleiIDPattern nuclei模板ID的格式要求
+var nucleiIDPattern = regexp.MustCompile(`^([a-zA-Z0-9]+[-_])*[a-zA-Z0-9]+$`)
+
+// nucleiMaxRedirects 导出模板跟随服务端跳转的最大次数, 与引擎一致
+const nucleiMaxRedirects = 15
+
+// nuclei 将指纹规则导出为nuclei模板, 每条规则一个文件
+// 参数:
+//   - tags: 指纹规则
+//
+// 返回值:
+//   - []ExportedFile: 模板文件, 文件名为模板ID
+//   - error: 错误信息
+func (ex *ruleExporter) nuclei(tags []pkg.Tag) ([]ExportedFile, error) {
+	var files []ExportedFile
+	names := make(map[string]int)
+	for _, tag := range tags {
+		template, ok := ex.nucleiTemplate(tag)
+		if !ok {
+			continue
+		}
+		// 不同规则的ID转换后可能相同, 追加序号避免文件覆盖
+		if n := names[template.ID]; n > 0 {
+			names[template.ID]++
+			template.ID = fmt.Sprintf("%s-%d", template.ID, n+1)
+		}
+		names[template.ID]++
+
+		var buf bytes.Buffer
+		encoder := yaml.NewEncoder(&buf)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(template); err != nil {
+			return nil, fmt.Errorf("序列化nuclei模板 %s 失败: %v", tag.ID, err)
+		}
+		files = append(files, ExportedFile{Name: template.ID + ".yaml", Data: buf.Bytes()})
+	}
+	return files, nil
+}
+
+// nucleiTemplate 转换单条规则
+// 参数:
+//   - tag: 指纹规则
+//
+// 返回值:
+//   - nucleiExportTemplate: nuclei模板
+//   - bool: 是否存在可导出的匹配器
+func (ex *ruleExporter) nucleiTemplate(tag pkg.Tag) (nucleiExportTemplate, bool) {
+	template := nucleiExportTemplate{ID: tag.ID, Info: nucleiInfoOf(tag.Info)}
+	if !nucleiIDPattern.MatchString(template.ID) {
+		template.ID = slug(tag.ID)
+	}
+
+	for _, group := range ex.groups(tag) {
+		if group.mixed {
+			ex.warn(tag, "http", "and模式匹配块的请求不同, 已统一使用第一个匹配块的请求")
+		}
+		var matchers, favicons []nucleiExportMatcher
+		for _, m := range group.matchers {
+			if m.Type == "favicon" {
+				if favicon, ok := ex.nucleiFavicon(tag, m); ok {
+					favicons = append(favicons, favicon)
+				}
+				continue
+			}
+			if matcher, ok := ex.nucleiMatcher(tag, m); ok {
+				matchers = append(matchers, matcher)
+			}
+		}
+
+		condition := ""
+		if group.and {
+			condition = "and"
+			// favicon需要单独请求, 无法与首页响应的匹配器同时成立
+			if len(favicons) > 0 && len(matchers) > 0 {
+				ex.warn(tag, "http", "favicon匹配器无法与其他匹配器组合为and条件, 已忽略")
+				favicons = nil
+			}
+		}
+		if len(matchers) > 0 {
+			template.HTTP = append(template.HTTP, nucleiRequestOf(group.http, condition, matchers))
+		}
+		if len(favicons) > 0 {
+			favicon := pkg.HTTP{Method: "GET", Path: []string{"/favicon.ico"}}
+			template.HTTP = append(template.HTTP, nucleiRequestOf(favicon, condition, favicons))
+		}
+	}
+
+	if len(template.HTTP) == 0 {
+		ex.warn(tag, "http", "没有可导出为nuclei的匹配器, 已跳过")
+		return template, false
+	}
+	return template, true
+}
+
+// nucleiInfoOf 转换规则信息, nuclei要求author非空
+func nucleiInfoOf(info pkg.Infos) nucleiExportInfo {
+	out := nucleiExportInfo{
+		Name:     info.Name,
+		Author:   info.Author,
+		Severity: info.Severity,
+		Tags:     info.Tags,
+	}
+	if out.Author == "" {
+		out.Author = "definger"
+	}
+	if out.Severity == "" {
+		out.Severity = "info"
+	}
+	metadata := make(map[string]interface{})
+	if info.Metadata.Vendor != "" {
+		metadata["vendor"] = info.Metadata.Vendor
+	}
+	if info.Metadata.Product != "" {
+		metadata["product"] = info.Metadata.Product
+	}
+	if info.Metadata.Verified {
+		metadata["verified"] = true
+	}
+	if len(metadata) > 0 {
+		out.Metadata = metadata
+	}
+	return out
+}
+
+// nucleiRequestOf 生成nuclei请求, 与引擎一致跟随服务端跳转
+func nucleiRequestOf(http pkg.HTTP, condition string, matchers []nucleiExportMatcher) nucleiExportRequest {
+	request := nucleiExportRequest{
+		Method:            strings.ToUpper(http.Method),
+		Headers:           http.Headers,
+		Body:              http.Body,
+		Redirects:         true,
+		MaxRedirects:      nucleiMaxRedirects,
+		MatchersCondition: condition,
+		Matchers:          matchers,
+	}
+	if request.Method == "" {
+		request.Method = "GET"
+	}
+	for _, path := range http.Path {
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+		request.Path = append(request.Path, "{{BaseURL}}"+path)
+	}
+	if len(request.Path) == 0 {
+		request.Path = []string{"{{BaseURL}}/"}
+	}
+	return request
+}
+
+// nucleiMatcher 转换关键字匹配器, 全部关键字为字面量时导出为word, 否则导出为regex
+// 引擎匹配时总是忽略大小写, 导出的匹配器同样忽略大小写
+// 参数:
+//   - tag: 指纹规则
+//   - m: 匹配器
+//
+// 返回值:
+//   - nucleiExportMatcher: nuclei匹配器
+//   - bool: 是否可以导出
+func (ex *ruleExporter) nucleiMatcher(tag pkg.Tag, m exportMatcher) (nucleiExportMatcher, bool) {
+	if m.Type != "word" {
+		ex.warn(tag, m.path, "不支持的匹配器类型 %s, 已忽略", m.Type)
+		return nucleiExportMatcher{}, false
+	}
+	part, ok := nucleiExportParts[m.Part]
+	if !ok {
+		ex.warn(tag, m.path+".part", "nuclei不支持匹配部位 %s, 已忽略", m.Part)
+		return nucleiExportMatcher{}, false
+	}
+	if len(m.Words) == 0 {
+		ex.warn(tag, m.path+".words", "没有关键字, 已忽略")
+		return nucleiExportMatcher{}, false
+	}
+	if m.Hops {
+		ex.warn(tag, m.path+".hops", "nuclei只匹配最终响应, 跳转中间响应的匹配已忽略")
+	}
+
+	matcher := nucleiExportMatcher{Part: part, Negative: m.Negative}
+	if m.Condition == "and" && len(m.Words) > 1 {
+		matcher.Condition = "and"
+	}
+	if literals, ok := literalWords(m.Words); ok {
+		matcher.Type, matcher.Words, matcher.CaseInsensitive = "word", literals, true
+		return matcher, true
+	}
+	matcher.Type = "regex"
+	for _, word := range m.Words {
+		matcher.Regex = append(matcher.Regex, "(?i)"+word)
+	}
+	return matcher, true
+}
+
+// nucleiFavicon 将favicon匹配器转换为favicon.ico响应上的dsl匹配器
+// 参数:
+//   - tag: 指纹规则
+//   - m: 匹配器
+//
+// 返回值:
+//   - nucleiExportMatcher: nuclei匹配器
+//   - bool: 是否可以导出
+func (ex *ruleExporter) nucleiFavicon(tag pkg.Tag, m exportMatcher) (nucleiExportMatcher, bool) {
+	var exprs []string
+	for _, hash := range m.Hash {
+		hash = strings.ToLower(strings.TrimSpace(hash))
+		if isMMH3Hash(hash) {
+			exprs = append(exprs, "mmh3(base64_py(body)) == "+strconv.Quote(hash))
+		} else {
+			exprs = append(exprs, "md5(body) == "+strconv.Quote(hash))
+		}
+	}
+	if len(exprs) == 0 {
+		ex.warn(tag, m.path+".hash", "没有favicon哈希, 已忽略")
+		return nucleiExportMatcher{}, false
+	}
+	return nucleiExportMatcher{Type: "dsl", DSL: exprs, Negative: m.Negative}, true
+}
@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+
+	"github.com/enenisme/definger/pkg"
+)
+
+func exportTags() []pkg.Tag {
+	return []pkg.Tag{
+		{
+			ID:   "acme-portal",
+			Info: pkg.Infos{Name: "Acme Portal", Severity: "info", Metadata: pkg.Metadatas{Vendor: "acme"}},
+			HTTP: []pkg.HTTP{{Method: "GET", Path: []string{"/"}, Mode: "and", Matchers: []pkg.Matchers{
+				{Type: "word", Part: "body", Words: []string{`acme\.js`, "portal"}, Condition: "and"},
+				{Type: "word", Part: "body", Words: []string{"demo"}, Negative: true},
+			}}},
+		},
+		{
+			ID:   "nginx",
+			Info: pkg.Infos{Name: "Nginx"},
+			HTTP: []pkg.HTTP{{Method: "GET", Path: []string{"/"}, Mode: "or", Matchers: []pkg.Matchers{
+				{Type: "word", Part: "header", Words: []string{`(?m)^server:.*nginx`}},
+				{Type: "favicon", Hash: []string{"-1234", "4644f2d45601037b8423d45e13194c93"}},
+				{Type: "word", Part: "cert.subject", Words: []string{"nginx"}},
+			}}},
+		},
+	}
+}
+
+func TestExportNuclei(t *testing.T) {
+	files, warnings, err := ExportRules("nuclei", exportTags())
+	assert.NoError(t, err)
+	assert.Len(t, files, 2)
+	assert.Equal(t, "acme-portal.yaml", files[0].Name)
+
+	// 导出的模板可以被nuclei模板导入重新加载
+	tags, _, err := parseNucleiTemplate(files[0].Data)
+	assert.NoError(t, err)
+	assert.Equal(t, "and", tags[0].HTTP[0].Mode)
+	assert.Len(t, tags[0].HTTP[0].Matchers, 2)
+
+	var template nucleiExportTemplate
+	assert.NoError(t, yaml.Unmarshal(files[1].Data, &template))
+	assert.Len(t, template.HTTP, 2)
+	assert.Equal(t, []string{"(?i)(?m)^server:.*nginx"}, template.HTTP[0].Matchers[0].Regex)
+	assert.Equal(t, []string{"{{BaseURL}}/favicon.ico"}, template.HTTP[1].Path)
+	assert.Equal(t, []string{`mmh3(base64_py(body)) == "-1234"`, `md5(body) == "4644f2d45601037b8423d45e13194c93"`}, template.HTTP[1].Matchers[0].DSL)
+
+	assert.Len(t, warnings, 1)
+	assert.Equal(t, "http[0].matchers[2].part", warnings[0].Path)
+}
+
+func TestExportEhole(t *testing.T) {
+	files, warnings, err := ExportRules("ehole", exportTags())
+	assert.NoError(t, err)
+
+	var finger eholeFinger
+	assert.NoError(t, json.Unmarshal(files[0].Data, &finger))
+	assert.Equal(t, []eholeRule{
+		{CMS: "Acme Portal", Method: "keyword", Location: "body", Keyword: []string{"acme.js", "portal"}},
+		{CMS: "Nginx", Method: "regular", Location: "header", Keyword: []string{"(?i)(?m)^server:.*nginx"}},
+		{CMS: "Nginx", Method: "faviconhash", Location: "body", Keyword: []string{"-1234"}},
+	}, finger.Fingerprint)
+
+	paths := make([]string, 0, len(warnings))
+	for _, warning := range warnings {
+		paths = append(paths, warning.Path)
+	}
+	assert.Equal(t, []string{"http[0].matchers[1].negative", "http[0].matchers[1].hash", "http[0].matchers[2].part"}, paths)
+}
+
+func TestExportWappalyzer(t *testing.T) {
+	files, _, err := ExportRules("wappalyzer", exportTags())
+	assert.NoError(t, err)
+
+	var technologies map[string]wappalyzerExportTech
+	assert.NoError(t, json.Unmarshal(files[0].Data, &technologies))
+	assert.Equal(t, []string{`^(?=[\s\S]*?acme\.js)(?=[\s\S]*?portal)(?!(?=[\s\S]*?(?:demo)))`}, technologies["Acme Portal"].HTML)
+	assert.Equal(t, map[string]string{"server": "nginx"}, technologies["Nginx"].Headers)
+
+	// 导出的文件可以重新导入, 前瞻断言不是RE2语法, 导入时被忽略
+	tags, _, err := ImportRules("wappalyzer", files[0].Data, nil)
+	assert.NoError(t, err)
+	assert.Len(t, tags, 1)
+	assert.True(t, strings.HasPrefix(tags[0].HTTP[0].Matchers[0].Words[0], "(?m)^server:"))
+}
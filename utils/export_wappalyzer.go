@@ -0,0 +1,292 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/enenisme/definger/pkg"
+)
+
+// wappalyzerExportTech 定义导出的Wappalyzer技术, 各特征之间为or关系
+type wappalyzerExportTech struct {
+	Headers    map[string]string `json:"headers,omitempty"`
+	HTML       []string          `json:"html,omitempty"`
+	CertIssuer string            `json:"certIssuer,omitempty"`
+	Implies    []string          `json:"implies,omitempty"`
+	Excludes   []string          `json:"excludes,omitempty"`
+	Requires   []string          `json:"requires,omitempty"`
+}
+
+// wappalyzerFeature 定义单个可独立命中的Wappalyzer特征
+type wappalyzerFeature struct {
+	header string // 响应头名称, 为空时为html特征
+	issuer bool   // 是否为证书颁发者特征
+	regex  string // JavaScript正则
+}
+
+// wappalyzerHeaderPattern 匹配导入或手写规则中以响应头名称开头的正则
+var wappalyzerHeaderPattern = regexp.MustCompile(`^(?:\(\?m\))?\^([a-z0-9_-]+):(?: \?| |\.\*|\[\^"'>\\n\]\*)*(.*)$`)
+
+// wappalyzerFlagPattern 匹配正则开头的标志组
+var wappalyzerFlagPattern = regexp.MustCompile(`^\(\?([a-zA-Z]+)\)`)
+
+// wappalyzer 将指纹规则导出为Wappalyzer的technologies JSON, 同名规则合并为一个技术
+// Wappalyzer的特征之间只有or关系, and条件仅在全部位于响应体时通过前瞻断言表示
+// 参数:
+//   - tags: 指纹规则
+//
+// 返回值:
+//   - []ExportedFile: technologies.json
+//   - error: 错误信息
+func (ex *ruleExporter) wappalyzer(tags []pkg.Tag) ([]ExportedFile, error) {
+	technologies := make(map[string]*wappalyzerExportTech)
+	for _, tag := range tags {
+		ex.checkRequest(tag, "Wappalyzer")
+		var features []wappalyzerFeature
+		for _, group := range ex.groups(tag) {
+			if group.and {
+				features = append(features, ex.wappalyzerAnd(tag, group)...)
+				continue
+			}
+			for _, m := range group.matchers {
+				if m.Negative {
+					ex.warn(tag, m.path+".negative", "Wappalyzer不支持单独的取反匹配, 已忽略")
+					continue
+				}
+				features = append(features, ex.wappalyzerFeatures(tag, m)...)
+			}
+		}
+		if len(features) == 0 {
+			ex.warn(tag, "http", "没有可导出为Wappalyzer的匹配器, 已跳过")
+			continue
+		}
+
+		name := tag.Info.Name
+		if name == "" {
+			name = tag.ID
+		}
+		tech, ok := technologies[name]
+		if !ok {
+			tech = &wappalyzerExportTech{}
+			technologies[name] = tech
+		}
+		tech.add(features)
+		tech.Implies = appendUnique(tech.Implies, tag.Info.Implies...)
+		tech.Excludes = appendUnique(tech.Excludes, tag.Info.Excludes...)
+		tech.Requires = appendUnique(tech.Requires, tag.Info.Requires...)
+	}
+
+	data, err := json.MarshalIndent(technologies, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("序列化Wappalyzer指纹失败: %v", err)
+	}
+	return []ExportedFile{{Name: "technologies.json", Data: data}}, nil
+}
+
+// add 将特征加入技术, 同一响应头或证书颁发者的多个特征以|合并
+func (t *wappalyzerExportTech) add(features []wappalyzerFeature) {
+	join := func(current, regex string) string {
+		if current == "" {
+			return regex
+		}
+		return "(?:" + current + ")|(?:" + regex + ")"
+	}
+	for _, feature := range features {
+		switch {
+		case feature.issuer:
+			t.CertIssuer = join(t.CertIssuer, feature.regex)
+		case feature.header != "":
+			if t.Headers == nil {
+				t.Headers = make(map[string]string)
+			}
+			t.Headers[feature.header] = join(t.Headers[feature.header], feature.regex)
+		default:
+			t.HTML = append(t.HTML, feature.regex)
+		}
+	}
+}
+
+// wappalyzerAnd 转换and组, 单个匹配器直接转换, 多个响应体匹配器合并为前瞻断言
+// 参数:
+//   - tag: 指纹规则
+//   - group: and组
+//
+// 返回值:
+//   - []wappalyzerFeature: 特征, 无法表示时为空
+func (ex *ruleExporter) wappalyzerAnd(tag pkg.Tag, group exportGroup) []wappalyzerFeature {
+	if len(group.matchers) == 1 && !group.matchers[0].Negative {
+		return ex.wappalyzerFeatures(tag, group.matchers[0])
+	}
+
+	var builder strings.Builder
+	builder.WriteString("^")
+	for _, m := range group.matchers {
+		if m.Type != "word" || m.Part != "body" {
+			ex.warn(tag, m.path, "Wappalyzer只能表示响应体关键字之间的and条件, 已跳过")
+			return nil
+		}
+		assertion, ok := ex.bodyAssertion(tag, m)
+		if !ok {
+			return nil
+		}
+		if m.Negative {
+			assertion = "(?!" + assertion + ")"
+		}
+		builder.WriteString(assertion)
+	}
+	return []wappalyzerFeature{{regex: builder.String()}}
+}
+
+// bodyAssertion 将响应体匹配器转换为前瞻断言, condition为and时每个关键字单独断言
+// 参数:
+//   - tag: 指纹规则
+//   - m: 匹配器
+//
+// 返回值:
+//   - string: 前瞻断言
+//   - bool: 是否可以转换
+func (ex *ruleExporter) bodyAssertion(tag pkg.Tag, m exportMatcher) (string, bool) {
+	var words []string
+	for _, word := range m.Words {
+		regex, ok := ex.jsPattern(tag, m.path+".words", word)
+		if !ok {
+			return "", false
+		}
+		words = append(words, regex)
+	}
+	if len(words) == 0 {
+		return "", true
+	}
+	if m.Condition != "and" {
+		return `(?=[\s\S]*?(?:` + strings.Join(words, "|") + `))`, true
+	}
+	var builder strings.Builder
+	for _, word := range words {
+		builder.WriteString(`(?=[\s\S]*?` + word + `)`)
+	}
+	return builder.String(), true
+}
+
+// wappalyzerFeatures 将单个匹配器转换为特征, 响应体以外的部位无法表示多个关键字的and条件
+// 参数:
+//   - tag: 指纹规则
+//   - m: 匹配器
+//
+// 返回值:
+//   - []wappalyzerFeature: 特征, 任一特征命中即匹配器成立
+func (ex *ruleExporter) wappalyzerFeatures(tag pkg.Tag, m exportMatcher) []wappalyzerFeature {
+	if m.Type != "word" {
+		ex.warn(tag, m.path, "Wappalyzer不支持%s匹配器, 已忽略", m.Type)
+		return nil
+	}
+	if m.Part != "body" && m.Part != "header" && m.Part != "cert.issuer" {
+		ex.warn(tag, m.path+".part", "Wappalyzer不支持匹配部位 %s, 已忽略", m.Part)
+		return nil
+	}
+	if m.Hops {
+		ex.warn(tag, m.path+".hops", "Wappalyzer只匹配最终响应, 跳转中间响应的匹配已忽略")
+	}
+	if m.Condition == "and" && len(m.Words) > 1 {
+		if m.Part != "body" {
+			ex.warn(tag, m.path+".condition", "Wappalyzer无法表示%s关键字之间的and条件, 已忽略", m.Part)
+			return nil
+		}
+		assertion, ok := ex.bodyAssertion(tag, m)
+		if !ok {
+			return nil
+		}
+		return []wappalyzerFeature{{regex: "^" + assertion}}
+	}
+
+	var features []wappalyzerFeature
+	for _, word := range m.Words {
+		feature := wappalyzerFeature{issuer: m.Part == "cert.issuer"}
+		if m.Part == "header" {
+			name, value, ok := splitHeaderWord(word)
+			if !ok {
+				ex.warn(tag, m.path+".words", "无法确定关键字 %q 对应的响应头名称, 已忽略", word)
+				continue
+			}
+			feature.header, word = name, value
+		}
+		regex, ok := ex.jsPattern(tag, m.path+".words", word)
+		if !ok {
+			continue
+		}
+		feature.regex = regex
+		features = append(features, feature)
+	}
+	return features
+}
+
+// jsPattern 将关键字正则转换为Wappalyzer使用的JavaScript正则
+// 开头的(?i)可以去除(Wappalyzer总是忽略大小写), 其他标志与RE2特有语法无法转换
+// 参数:
+//   - tag: 指纹规则
+//   - path: 关键字位置
+//   - word: 关键字正则
+//
+// 返回值:
+//   - string: JavaScript正则
+//   - bool: 是否可以转换
+func (ex *ruleExporter) jsPattern(tag pkg.Tag, path, word string) (string, bool) {
+	if match := wappalyzerFlagPattern.FindStringSubmatch(word); match != nil {
+		if strings.Trim(match[1], "i") != "" {
+			ex.warn(tag, path, "Wappalyzer不支持正则标志 %s, 已忽略关键字 %q", match[1], word)
+			return "", false
+		}
+		word = word[len(match[0]):]
+	}
+	for _, token := range []string{`\z`, `\A`, `\Q`, `\C`, `\p`, `\P`, `[[:`} {
+		if strings.Contains(word, token) {
+			ex.warn(tag, path, "Wappalyzer不支持正则语法 %s, 已忽略关键字 %q", token, word)
+			return "", false
+		}
+	}
+	if strings.Contains(strings.ReplaceAll(word, "(?:", ""), "(?") {
+		ex.warn(tag, path, "Wappalyzer不支持正则中的标志或命名分组, 已忽略关键字 %q", word)
+		return "", false
+	}
+	// \; 在Wappalyzer中为附加信息分隔符
+	return strings.ReplaceAll(word, `\;`, ";"), true
+}
+
+// splitHeaderWord 从响应头关键字中拆分响应头名称与值正则
+// 支持 (?m)^name: value 形式的正则与 name: value 形式的字面量
+// 参数:
+//   - word: 关键字正则
+//
+// 返回值:
+//   - string: 响应头名称(小写)
+//   - string: 值正则
+//   - bool: 是否可以拆分
+func splitHeaderWord(word string) (string, string, bool) {
+	if match := wappalyzerHeaderPattern.FindStringSubmatch(word); match != nil {
+		return match[1], match[2], true
+	}
+	if literal, ok := literalWord(word); ok {
+		if name, value, found := strings.Cut(literal, ":"); found && name != "" && !strings.ContainsAny(name, " \t") {
+			return strings.ToLower(name), regexp.QuoteMeta(strings.TrimSpace(value)), true
+		}
+	}
+	return "", "", false
+}
+
+// appendUnique 追加列表中尚不存在的字符串
+func appendUnique(list []string, items ...string) []string {
+	for _, item := range items {
+		exists := false
+		for _, existing := range list {
+			if existing == item {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -0,0 +1,412 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 表达式节点类型
+const (
+	ExprCond = "" // 条件
+	ExprAnd  = "and"
+	ExprOr   = "or"
+	ExprNot  = "not"
+)
+
+// Expr 定义规则表达式的语法树节点
+type Expr struct {
+	Op   string  // 节点类型
+	Args []*Expr // and/or/not的子节点
+	Cond Cond    // 条件节点的内容
+}
+
+// Cond 定义表达式中的单个条件
+type Cond struct {
+	Field string // 字段, 例如 title/body/header
+	Op    string // 比较运算符, = 表示包含, == 表示完全一致
+	Value string // 比较值
+}
+
+// Literal 定义析取范式中的文字, 即可能取反的条件
+type Literal struct {
+	Cond
+	Negated bool // 是否取反
+}
+
+// DNF 将表达式转换为析取范式, 即若干合取项的或
+// 参数:
+//   - expr: 表达式
+//   - limit: 合取项数量上限, 防止展开后规模爆炸
+//
+// 返回值:
+//   - [][]Literal: 合取项列表
+//   - error: 超出上限时返回错误
+func DNF(expr *Expr, limit int) ([][]Literal, error) {
+	terms, err := dnf(expr, false, limit)
+	if err != nil {
+		return nil, err
+	}
+	return terms, nil
+}
+
+// dnf 递归展开表达式, negated表示外层存在取反, 按德摩根定律下推
+func dnf(expr *Expr, negated bool, limit int) ([][]Literal, error) {
+	op := expr.Op
+	if negated {
+		// 德摩根定律: 取反后and与or互换
+		switch op {
+		case ExprAnd:
+			op = ExprOr
+		case ExprOr:
+			op = ExprAnd
+		}
+	}
+
+	switch op {
+	case ExprCond:
+		return [][]Literal{{{Cond: expr.Cond, Negated: negated}}}, nil
+	case ExprNot:
+		return dnf(expr.Args[0], !negated, limit)
+	case ExprOr:
+		var terms [][]Literal
+		for _, arg := range expr.Args {
+			sub, err := dnf(arg, negated, limit)
+			if err != nil {
+				return nil, err
+			}
+			terms = append(terms, sub...)
+			if len(terms) > limit {
+				return nil, fmt.Errorf("表达式展开后超过 %d 个合取项", limit)
+			}
+		}
+		return terms, nil
+	case ExprAnd:
+		terms := [][]Literal{{}}
+		for _, arg := range expr.Args {
+			sub, err := dnf(arg, negated, limit)
+			if err != nil {
+				return nil, err
+			}
+			if len(terms)*len(sub) > limit {
+				return nil, fmt.Errorf("表达式展开后超过 %d 个合取项", limit)
+			}
+			// 分配律: (a|b)&(c|d) = ac|ad|bc|bd
+			product := make([][]Literal, 0, len(terms)*len(sub))
+			for _, left := range terms {
+				for _, right := range sub {
+					term := make([]Literal, 0, len(left)+len(right))
+					term = append(append(term, left...), right...)
+					product = append(product, term)
+				}
+			}
+			terms = product
+		}
+		return terms, nil
+	default:
+		return nil, fmt.Errorf("未知的表达式节点: %s", expr.Op)
+	}
+}
+
+// exprParser 定义递归下降解析器的公共部分
+type exprParser struct {
+	input string
+	pos   int
+}
+
+// skipSpace 跳过空白字符
+func (p *exprParser) skipSpace() {
+	for p.pos < len(p.input) && strings.ContainsRune(" \t\r\n", rune(p.input[p.pos])) {
+		p.pos++
+	}
+}
+
+// consume 在当前位置匹配token时前进并返回true
+func (p *exprParser) consume(token string) bool {
+	p.skipSpace()
+	if strings.HasPrefix(p.input[p.pos:], token) {
+		p.pos += len(token)
+		return true
+	}
+	return false
+}
+
+// errorf 生成带位置信息的解析错误
+func (p *exprParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("第 %d 个字符处: %s", p.pos+1, fmt.Sprintf(format, args...))
+}
+
+// ParseFOFA 解析FOFA风格的查询表达式, 例如 title="x" && (header="y" || body="z")
+// 参数:
+//   - query: 查询表达式
+//
+// 返回值:
+//   - *Expr: 表达式语法树
+//   - error: 错误信息
+func ParseFOFA(query string) (*Expr, error) {
+	p := &fofaParser{exprParser{input: query}}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.skipSpace(); p.pos < len(p.input) {
+		return nil, p.errorf("多余的内容 %q", p.input[p.pos:])
+	}
+	return expr, nil
+}
+
+// fofaParser 定义FOFA查询表达式解析器
+type fofaParser struct {
+	exprParser
+}
+
+// parseOr 解析 ||
+func (p *fofaParser) parseOr() (*Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	args := []*Expr{left}
+	for p.consume("||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, right)
+	}
+	if len(args) == 1 {
+		return left, nil
+	}
+	return &Expr{Op: ExprOr, Args: args}, nil
+}
+
+// parseAnd 解析 &&
+func (p *fofaParser) parseAnd() (*Expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	args := []*Expr{left}
+	for p.consume("&&") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, right)
+	}
+	if len(args) == 1 {
+		return left, nil
+	}
+	return &Expr{Op: ExprAnd, Args: args}, nil
+}
+
+// parseUnary 解析括号与条件
+func (p *fofaParser) parseUnary() (*Expr, error) {
+	if p.consume("(") {
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.consume(")") {
+			return nil, p.errorf("缺少右括号")
+		}
+		return expr, nil
+	}
+	return p.parseCond()
+}
+
+// parseCond 解析 field="value", 运算符支持 = == !=
+func (p *fofaParser) parseCond() (*Expr, error) {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.input) && (isFieldChar(p.input[p.pos])) {
+		p.pos++
+	}
+	field := strings.ToLower(p.input[start:p.pos])
+	if field == "" {
+		return nil, p.errorf("缺少字段名")
+	}
+
+	var op string
+	switch {
+	case p.consume("=="):
+		op = "=="
+	case p.consume("!="):
+		op = "!="
+	case p.consume("="):
+		op = "="
+	default:
+		return nil, p.errorf("字段 %s 缺少运算符", field)
+	}
+
+	value, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	expr := &Expr{Cond: Cond{Field: field, Op: op, Value: value}}
+	if op == "!=" {
+		expr.Cond.Op = "="
+		expr = &Expr{Op: ExprNot, Args: []*Expr{expr}}
+	}
+	return expr, nil
+}
+
+// parseValue 解析带引号的字符串, 支持 \" 与 \\ 转义; 不带引号时读取到空白或右括号为止
+func (p *fofaParser) parseValue() (string, error) {
+	p.skipSpace()
+	if p.pos >= len(p.input) {
+		return "", p.errorf("缺少比较值")
+	}
+	if p.input[p.pos] != '"' {
+		start := p.pos
+		for p.pos < len(p.input) && !strings.ContainsRune(" \t\r\n)", rune(p.input[p.pos])) {
+			p.pos++
+		}
+		return p.input[start:p.pos], nil
+	}
+
+	p.pos++
+	var value strings.Builder
+	for p.pos < len(p.input) {
+		c := p.input[p.pos]
+		switch {
+		case c == '\\' && p.pos+1 < len(p.input):
+			value.WriteByte(p.input[p.pos+1])
+			p.pos += 2
+		case c == '"':
+			p.pos++
+			return value.String(), nil
+		default:
+			value.WriteByte(c)
+			p.pos++
+		}
+	}
+	return "", p.errorf("字符串缺少结束引号")
+}
+
+// isFieldChar 判断是否为字段名字符
+func isFieldChar(c byte) bool {
+	return c == '_' || c == '.' || c == '-' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
+
+// ParseLegacy 解析旧版指纹的匹配表达式, 例如 (a|b)&&!c
+// | 或 || 表示或, && 表示与, ! 表示取反, 括号用于分组, \ 转义下一个字符;
+// 关键字中间的 (、! 与单个 & 按普通字符处理, 关键字首尾空白会被去除
+// 参数:
+//   - input: 匹配表达式
+//   - field: 条件字段, 例如 body/header
+//
+// 返回值:
+//   - *Expr: 表达式语法树
+//   - error: 错误信息
+func ParseLegacy(input, field string) (*Expr, error) {
+	p := &legacyParser{exprParser: exprParser{input: input}, field: field}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.skipSpace(); p.pos < len(p.input) {
+		return nil, p.errorf("多余的内容 %q", p.input[p.pos:])
+	}
+	return expr, nil
+}
+
+// legacyParser 定义旧版匹配表达式解析器
+type legacyParser struct {
+	exprParser
+	field string
+	depth int // 当前括号深度, 深度为0时 ) 按普通字符处理
+}
+
+// parseOr 解析 | 与 ||
+func (p *legacyParser) parseOr() (*Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	args := []*Expr{left}
+	for p.consume("|") {
+		p.consume("|")
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, right)
+	}
+	if len(args) == 1 {
+		return left, nil
+	}
+	return &Expr{Op: ExprOr, Args: args}, nil
+}
+
+// parseAnd 解析 &&
+func (p *legacyParser) parseAnd() (*Expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	args := []*Expr{left}
+	for p.consume("&&") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, right)
+	}
+	if len(args) == 1 {
+		return left, nil
+	}
+	return &Expr{Op: ExprAnd, Args: args}, nil
+}
+
+// parseUnary 解析取反、括号与关键字
+func (p *legacyParser) parseUnary() (*Expr, error) {
+	switch {
+	case p.consume("!"):
+		arg, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &Expr{Op: ExprNot, Args: []*Expr{arg}}, nil
+	case p.consume("("):
+		p.depth++
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.consume(")") {
+			return nil, p.errorf("缺少右括号")
+		}
+		p.depth--
+		return expr, nil
+	default:
+		return p.parseLiteral()
+	}
+}
+
+// parseLiteral 读取关键字直到遇到未转义的运算符
+func (p *legacyParser) parseLiteral() (*Expr, error) {
+	p.skipSpace()
+	var value strings.Builder
+	trimmed := 0 // 最后一个转义字符之后的长度, 转义的空白不去除
+	for p.pos < len(p.input) {
+		c := p.input[p.pos]
+		if c == '\\' && p.pos+1 < len(p.input) {
+			value.WriteByte(p.input[p.pos+1])
+			p.pos += 2
+			trimmed = value.Len()
+			continue
+		}
+		if c == '|' || strings.HasPrefix(p.input[p.pos:], "&&") || (c == ')' && p.depth > 0) {
+			break
+		}
+		value.WriteByte(c)
+		p.pos++
+	}
+
+	literal := value.String()
+	literal = literal[:trimmed] + strings.TrimRight(literal[trimmed:], " \t\r\n")
+	if literal == "" {
+		return nil, p.errorf("关键字为空")
+	}
+	return &Expr{Cond: Cond{Field: p.field, Op: "=", Value: literal}}, nil
+}
@@ -0,0 +1,24 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFOFADNF(t *testing.T) {
+	expr, err := ParseFOFA(`(title="A \"B\"" || body="c") && header!="d"`)
+	assert.NoError(t, err)
+
+	terms, err := DNF(expr, 8)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]Literal{
+		{{Cond: Cond{Field: "title", Op: "=", Value: `A "B"`}}, {Cond: Cond{Field: "header", Op: "=", Value: "d"}, Negated: true}},
+		{{Cond: Cond{Field: "body", Op: "=", Value: "c"}}, {Cond: Cond{Field: "header", Op: "=", Value: "d"}, Negated: true}},
+	}, terms)
+
+	_, err = DNF(expr, 1)
+	assert.Error(t, err)
+	_, err = ParseFOFA(`title="x" && (body="y"`)
+	assert.Error(t, err)
+}
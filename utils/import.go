@@ -0,0 +1,383 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/enenisme/definger/pkg"
+)
+
+// ImportFormats 支持导入的指纹库格式
+var ImportFormats = []string{"legacy", "ehole", "wappalyzer", "fofa"}
+
+// maxDNFTerms 表达式展开为析取范式后的合取项上限
+const maxDNFTerms = 32
+
+// ImportRules 将其他指纹库格式转换为指纹规则
+// 参数:
+//   - format: 源格式, 取值见ImportFormats
+//   - data: 源文件内容
+//   - mapping: 元数据映射, 按指纹名称填充作者、标签、厂商与产品, 可为空
+//
+// 返回值:
+//   - []pkg.Tag: 指纹规则
+//   - []pkg.Issue: 无法完整转换的警告, Path为源文件中的位置
+//   - error: 错误信息
+func ImportRules(format string, data []byte, mapping MetaMapping) ([]pkg.Tag, []pkg.Issue, error) {
+	var (
+		tags     []pkg.Tag
+		warnings []pkg.Issue
+		err      error
+	)
+	switch strings.ToLower(format) {
+	case "legacy":
+		return importLegacy(data, mapping)
+	case "ehole":
+		tags, warnings, err = newRuleImporter("ehole").ehole(data)
+	case "wappalyzer":
+		tags, warnings, err = newRuleImporter("wappalyzer").wappalyzer(data)
+	case "fofa":
+		tags, warnings, err = newRuleImporter("fofa").fofa(data)
+	default:
+		return nil, nil, fmt.Errorf("不支持的导入格式: %s, 可选: %s", format, strings.Join(ImportFormats, ", "))
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	mapping.Apply(tags)
+	return tags, warnings, nil
+}
+
+// importLegacy 转换旧版指纹(JSON)文件, 与Json2Json使用同一套转换逻辑
+func importLegacy(data []byte, mapping MetaMapping) ([]pkg.Tag, []pkg.Issue, error) {
+	var jsonData []JsonData
+	if err := json.Unmarshal(data, &jsonData); err != nil {
+		return nil, nil, fmt.Errorf("解析旧版指纹文件失败: %v", err)
+	}
+
+	tags, warnings := json2Json(jsonData, mapping)
+	return tags, warnings, nil
+}
+
+// ruleImporter 定义导入过程的公共状态
+type ruleImporter struct {
+	format   string
+	ids      map[string]int // 已分配的规则ID, 用于去重
+	warnings []pkg.Issue
+}
+
+// newRuleImporter 创建导入器
+// 参数:
+//   - format: 源格式, 同时作为规则ID前缀与标签
+//
+// 返回值:
+//   - *ruleImporter: 导入器
+func newRuleImporter(format string) *ruleImporter {
+	return &ruleImporter{format: format, ids: make(map[string]int)}
+}
+
+// warn 记录转换警告
+func (im *ruleImporter) warn(id, path, format string, args ...interface{}) {
+	im.warnings = append(im.warnings, pkg.Issue{ID: id, Path: path, Level: pkg.IssueWarning, Message: fmt.Sprintf(format, args...)})
+}
+
+// newTag 创建导入的指纹规则, 规则ID由格式与产品名生成并保证唯一
+// 参数:
+//   - name: 指纹名称
+//
+// 返回值:
+//   - pkg.Tag: 指纹规则, HTTP匹配块由调用方填充
+func (im *ruleImporter) newTag(name string) pkg.Tag {
+	id := im.format + "-" + slug(name)
+	if n := im.ids[id]; n > 0 {
+		im.ids[id]++
+		id = fmt.Sprintf("%s-%d", id, n+1)
+	}
+	im.ids[id]++
+	return pkg.Tag{
+		ID: id,
+		Info: pkg.Infos{
+			Name:     name,
+			Tags:     im.format,
+			Severity: "info",
+		},
+	}
+}
+
+// slug 将名称转换为规则ID片段, 保留字母与数字, 其余字符替换为-
+// 参数:
+//   - name: 名称
+//
+// 返回值:
+//   - string: ID片段
+func slug(name string) string {
+	var builder strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			builder.WriteRune(r)
+			dash = false
+		} else if !dash && builder.Len() > 0 {
+			builder.WriteByte('-')
+			dash = true
+		}
+	}
+	s := strings.TrimSuffix(builder.String(), "-")
+	if s == "" {
+		return "unnamed"
+	}
+	return s
+}
+
+// wordMatcher 创建word匹配器
+func wordMatcher(part, condition string, words ...string) pkg.Matchers {
+	return pkg.Matchers{Type: "word", Part: part, Condition: condition, Words: words, CaseInsensitive: true}
+}
+
+// validPattern 判断正则能否按关键字规则编译
+func validPattern(pattern string) bool {
+	_, err := regexp.Compile("(?i)(" + pattern + ")")
+	return err == nil
+}
+
+// exprHTTP 将表达式转换为HTTP匹配块, 同一规则内任一匹配块命中即命中
+// 顶层的或拆分为多个匹配块: 只含一个匹配器的或分支合并为一个or块, 其余分支各自生成and块;
+// 分支内的或条件能合并为一个匹配器时直接使用or关键字, 否则展开为析取范式
+// 参数:
+//   - name: 指纹名称, 用于警告
+//   - expr: 表达式
+//   - path: 表达式在源文件中的位置
+//   - convert: 将条件转换为匹配器的函数, 无法转换时返回错误
+//
+// 返回值:
+//   - []pkg.HTTP: 匹配块, Method与Path由调用方填充; 无法转换时为空
+func (im *ruleImporter) exprHTTP(name string, expr *Expr, path string, convert func(Cond) (pkg.Matchers, error)) []pkg.HTTP {
+	literal := func(l Literal) (pkg.Matchers, bool) {
+		matcher, err := convert(l.Cond)
+		if err != nil {
+			// 忽略条件会改变匹配范围, 提示人工复核
+			im.warn("", path, "%s: %v, 已忽略该条件", name, err)
+			return pkg.Matchers{}, false
+		}
+		matcher.Negative = l.Negated
+		return matcher, true
+	}
+
+	var singles []pkg.Matchers
+	var blocks [][]pkg.Matchers
+	addBlock := func(matchers []pkg.Matchers) {
+		switch len(matchers) {
+		case 0:
+		case 1:
+			singles = append(singles, matchers[0])
+		default:
+			blocks = append(blocks, matchers)
+		}
+	}
+
+	expr = normalizeExpr(expr, false)
+	alternatives := []*Expr{expr}
+	if expr.Op == ExprOr {
+		alternatives = expr.Args
+	}
+	for _, alt := range alternatives {
+		// 尝试失败时丢弃尝试过程中的警告, 展开后重新转换
+		warnings := len(im.warnings)
+		if matchers, ok := im.nestedMatchers(alt, literal); ok {
+			addBlock(matchers)
+			continue
+		}
+		im.warnings = im.warnings[:warnings]
+
+		// 无法直接表示的分支展开为析取范式
+		terms, err := DNF(alt, maxDNFTerms)
+		if err != nil {
+			im.warn("", path, "%s: %v, 已跳过", name, err)
+			return nil
+		}
+		for _, term := range terms {
+			var matchers []pkg.Matchers
+			for _, l := range term {
+				if matcher, ok := literal(l); ok {
+					matchers = append(matchers, matcher)
+				}
+			}
+			addBlock(matchers)
+		}
+	}
+
+	var https []pkg.HTTP
+	if len(singles) > 0 {
+		https = append(https, pkg.HTTP{Mode: "or", Matchers: mergeSingles(singles)})
+	}
+	for _, matchers := range blocks {
+		https = append(https, pkg.HTTP{Mode: "and", Matchers: matchers})
+	}
+	if len(https) == 0 {
+		im.warn("", path, "%s: 没有可转换的条件, 已跳过", name)
+	}
+	return https
+}
+
+// nestedMatchers 将合取分支直接转换为and块的匹配器, 每个合取项需能表示为一个匹配器
+// 参数:
+//   - alt: 取反已下推的合取分支
+//   - literal: 将文字转换为匹配器的函数
+//
+// 返回值:
+//   - []pkg.Matchers: 匹配器
+//   - bool: 是否可以直接表示
+func (im *ruleImporter) nestedMatchers(alt *Expr, literal func(Literal) (pkg.Matchers, bool)) ([]pkg.Matchers, bool) {
+	conjuncts := []*Expr{alt}
+	if alt.Op == ExprAnd {
+		conjuncts = alt.Args
+	}
+
+	var matchers []pkg.Matchers
+	for _, conj := range conjuncts {
+		literals, ok := exprLiterals(conj)
+		if !ok {
+			return nil, false
+		}
+		var group []pkg.Matchers
+		for _, l := range literals {
+			if matcher, ok := literal(l); ok {
+				group = append(group, matcher)
+			}
+		}
+		if len(group) == 0 {
+			continue
+		}
+		merged, ok := mergeOr(group)
+		if !ok {
+			return nil, false
+		}
+		matchers = append(matchers, merged)
+	}
+	return matchers, true
+}
+
+// mergeSingles 合并or块中可以合并的匹配器, 保持首次出现的顺序
+// 参数:
+//   - matchers: or关系的匹配器
+//
+// 返回值:
+//   - []pkg.Matchers: 合并后的匹配器
+func mergeSingles(matchers []pkg.Matchers) []pkg.Matchers {
+	var result []pkg.Matchers
+	for _, m := range matchers {
+		merged := false
+		for i := range result {
+			if combined, ok := mergeOr([]pkg.Matchers{result[i], m}); ok {
+				result[i], merged = combined, true
+				break
+			}
+		}
+		if !merged {
+			result = append(result, m)
+		}
+	}
+	return result
+}
+
+// normalizeExpr 将取反下推到条件并展开嵌套的同类节点
+// 参数:
+//   - expr: 表达式
+//   - negated: 外层是否存在取反
+//
+// 返回值:
+//   - *Expr: 只在条件外层出现取反的表达式
+func normalizeExpr(expr *Expr, negated bool) *Expr {
+	switch expr.Op {
+	case ExprCond:
+		if negated {
+			return &Expr{Op: ExprNot, Args: []*Expr{expr}}
+		}
+		return expr
+	case ExprNot:
+		return normalizeExpr(expr.Args[0], !negated)
+	}
+
+	op := expr.Op
+	if negated {
+		// 德摩根定律
+		if op == ExprAnd {
+			op = ExprOr
+		} else {
+			op = ExprAnd
+		}
+	}
+	result := &Expr{Op: op}
+	for _, arg := range expr.Args {
+		arg = normalizeExpr(arg, negated)
+		if arg.Op == op {
+			result.Args = append(result.Args, arg.Args...)
+		} else {
+			result.Args = append(result.Args, arg)
+		}
+	}
+	return result
+}
+
+// exprLiterals 获取条件或条件之或中的全部文字
+// 参数:
+//   - expr: 取反已下推的表达式
+//
+// 返回值:
+//   - []Literal: 文字, 之间为或关系
+//   - bool: 表达式是否只由文字与或组成
+func exprLiterals(expr *Expr) ([]Literal, bool) {
+	switch expr.Op {
+	case ExprCond:
+		return []Literal{{Cond: expr.Cond}}, true
+	case ExprNot:
+		return []Literal{{Cond: expr.Args[0].Cond, Negated: true}}, true
+	case ExprOr:
+		var literals []Literal
+		for _, arg := range expr.Args {
+			sub, ok := exprLiterals(arg)
+			if !ok {
+				return nil, false
+			}
+			literals = append(literals, sub...)
+		}
+		return literals, true
+	default:
+		return nil, false
+	}
+}
+
+// mergeOr 将或关系的匹配器合并为一个匹配器
+// 只有同一部位、均未取反的word匹配器, 或均未取反的favicon匹配器可以合并
+// 参数:
+//   - matchers: 匹配器
+//
+// 返回值:
+//   - pkg.Matchers: 合并后的匹配器
+//   - bool: 是否可以合并
+func mergeOr(matchers []pkg.Matchers) (pkg.Matchers, bool) {
+	if len(matchers) == 1 {
+		return matchers[0], true
+	}
+	merged := matchers[0]
+	merged.Condition = ""
+	merged.Words = nil
+	merged.Hash = nil
+	for _, m := range matchers {
+		if m.Negative || m.Type != merged.Type || m.Part != merged.Part || m.Hops != merged.Hops {
+			return pkg.Matchers{}, false
+		}
+		if m.Condition == "and" && len(m.Words) > 1 {
+			return pkg.Matchers{}, false
+		}
+		merged.Words = append(merged.Words, m.Words...)
+		merged.Hash = append(merged.Hash, m.Hash...)
+	}
+	if merged.Type != "word" && merged.Type != "favicon" {
+		return pkg.Matchers{}, false
+	}
+	return merged, true
+}
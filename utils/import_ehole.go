@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"github.com/enenisme/definger/pkg"
+)
+
+// eholeFinger 定义EHole的finger.json
+type eholeFinger struct {
+	Fingerprint []eholeRule `json:"fingerprint"`
+}
+
+// eholeRule 定义EHole的单条指纹
+type eholeRule struct {
+	CMS      string   `json:"cms"`
+	Method   string   `json:"method"`   // keyword/regular/faviconhash
+	Location string   `json:"location"` // body/header/title
+	Keyword  []string `json:"keyword"`  // 关键字之间为and关系
+}
+
+// eholeLocations EHole匹配位置到匹配部位的映射
+var eholeLocations = map[string]string{
+	"body":   "body",
+	"header": "header",
+	"title":  "title",
+}
+
+// ehole 转换EHole的finger.json, 每条指纹生成一条规则
+// 参数:
+//   - data: finger.json内容
+//
+// 返回值:
+//   - []pkg.Tag: 指纹规则
+//   - []pkg.Issue: 转换警告
+//   - error: 错误信息
+func (im *ruleImporter) ehole(data []byte) ([]pkg.Tag, []pkg.Issue, error) {
+	var finger eholeFinger
+	if err := json.Unmarshal(data, &finger); err != nil {
+		return nil, nil, fmt.Errorf("解析EHole指纹文件失败: %v", err)
+	}
+
+	var tags []pkg.Tag
+	for i, rule := range finger.Fingerprint {
+		path := fmt.Sprintf("$.fingerprint[%d]", i)
+		if rule.CMS == "" || len(rule.Keyword) == 0 {
+			im.warn("", path, "缺少cms或keyword, 已跳过")
+			continue
+		}
+
+		var matcher pkg.Matchers
+		switch rule.Method {
+		case "faviconhash":
+			matcher = pkg.Matchers{Type: "favicon", Hash: rule.Keyword}
+		case "keyword", "regular":
+			part, ok := eholeLocations[rule.Location]
+			if !ok {
+				im.warn("", path+".location", "%s: 不支持的匹配位置 %s, 已跳过", rule.CMS, rule.Location)
+				continue
+			}
+			words := make([]string, 0, len(rule.Keyword))
+			for _, keyword := range rule.Keyword {
+				if rule.Method == "keyword" {
+					keyword = regexp.QuoteMeta(keyword)
+				}
+				words = append(words, keyword)
+			}
+			if invalid := firstInvalid(words); invalid != "" {
+				im.warn("", path+".keyword", "%s: 正则 %q 无法编译, 已跳过", rule.CMS, invalid)
+				continue
+			}
+			matcher = wordMatcher(part, "and", words...)
+		default:
+			im.warn("", path+".method", "%s: 不支持的匹配方式 %s, 已跳过", rule.CMS, rule.Method)
+			continue
+		}
+
+		tag := im.newTag(rule.CMS)
+		tag.HTTP = []pkg.HTTP{{Method: "GET", Path: []string{"/"}, Matchers: []pkg.Matchers{matcher}}}
+		tags = append(tags, tag)
+	}
+	return tags, im.warnings, nil
+}
+
+// firstInvalid 获取第一个无法编译的正则, 全部合法时返回空字符串
+func firstInvalid(patterns []string) string {
+	for _, pattern := range patterns {
+		if !validPattern(pattern) {
+			return pattern
+		}
+	}
+	return ""
+}
@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/enenisme/definger/pkg"
+)
+
+// fofaRule 定义FOFA风格的指纹规则, 兼容常见开源规则库的字段名
+type fofaRule struct {
+	Name    string `json:"name"`
+	Product string `json:"product"`
+	Rule    string `json:"rule"`
+	Query   string `json:"query"`
+}
+
+// fofaFields FOFA字段到匹配部位的映射, 未列出的字段无法在HTTP响应中匹配
+var fofaFields = map[string]string{
+	"title":        "title",
+	"body":         "body",
+	"header":       "header",
+	"banner":       "header",
+	"server":       "header",
+	"cert":         "cert.subject",
+	"cert.subject": "cert.subject",
+	"cert.issuer":  "cert.issuer",
+}
+
+// fofa 转换FOFA风格的规则列表, 例如 [{"name": "x", "rule": "title=\"x\" && header=\"y\""}]
+// 表达式顶层的或无法在一个匹配块中表示时拆分为多条同名规则
+// 参数:
+//   - data: 规则列表内容
+//
+// 返回值:
+//   - []pkg.Tag: 指纹规则
+//   - []pkg.Issue: 转换警告
+//   - error: 错误信息
+func (im *ruleImporter) fofa(data []byte) ([]pkg.Tag, []pkg.Issue, error) {
+	var rules []fofaRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, nil, fmt.Errorf("解析FOFA规则文件失败: %v", err)
+	}
+
+	var tags []pkg.Tag
+	for i, rule := range rules {
+		path := fmt.Sprintf("$[%d]", i)
+		name, query := firstNonEmpty(rule.Name, rule.Product), firstNonEmpty(rule.Rule, rule.Query)
+		if name == "" || query == "" {
+			im.warn("", path, "缺少名称或规则, 已跳过")
+			continue
+		}
+
+		expr, err := ParseFOFA(query)
+		if err != nil {
+			im.warn("", path+".rule", "%s: 解析规则失败: %v", name, err)
+			continue
+		}
+		https := im.exprHTTP(name, expr, path+".rule", fofaMatcher)
+		if len(https) == 0 {
+			continue
+		}
+		for j := range https {
+			https[j].Method, https[j].Path = "GET", []string{"/"}
+		}
+		tag := im.newTag(name)
+		tag.HTTP = https
+		tags = append(tags, tag)
+	}
+	return tags, im.warnings, nil
+}
+
+// fofaMatcher 将FOFA条件转换为匹配器
+// 参数:
+//   - cond: 条件
+//
+// 返回值:
+//   - pkg.Matchers: 匹配器
+//   - error: 条件无法转换时的原因
+func fofaMatcher(cond Cond) (pkg.Matchers, error) {
+	if cond.Field == "icon_hash" {
+		return pkg.Matchers{Type: "favicon", Hash: []string{cond.Value}}, nil
+	}
+
+	part, ok := fofaFields[cond.Field]
+	if !ok {
+		return pkg.Matchers{}, fmt.Errorf("不支持的字段 %s", cond.Field)
+	}
+	word := regexp.QuoteMeta(cond.Value)
+	switch {
+	case cond.Field == "server":
+		// 响应头部位为小写的 name: value 行
+		word = `(?m)^server:.*` + word
+	case cond.Op == "==" && part == "title":
+		word = "^" + word + "$"
+	}
+	return wordMatcher(part, "", word), nil
+}
+
+// firstNonEmpty 获取第一个非空字符串
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/enenisme/definger/pkg"
+)
+
+func TestImportEHole(t *testing.T) {
+	data := `{"fingerprint":[
+		{"cms":"致远OA","method":"keyword","location":"body","keyword":["/seeyon/USER-DATA/IMAGES/LOGIN/login.gif"]},
+		{"cms":"致远OA","method":"faviconhash","location":"body","keyword":["-1234"]},
+		{"cms":"Broken","method":"regular","location":"body","keyword":["(unclosed"]}]}`
+
+	tags, warnings, err := ImportRules("ehole", []byte(data), nil)
+	assert.NoError(t, err)
+	assert.Len(t, tags, 2)
+	assert.Equal(t, []string{"ehole-致远oa", "ehole-致远oa-2"}, []string{tags[0].ID, tags[1].ID})
+	assert.Equal(t, `/seeyon/USER-DATA/IMAGES/LOGIN/login\.gif`, tags[0].HTTP[0].Matchers[0].Words[0])
+	assert.Equal(t, pkg.Matchers{Type: "favicon", Hash: []string{"-1234"}}, tags[1].HTTP[0].Matchers[0])
+	assert.Len(t, warnings, 1)
+	assert.Empty(t, pkg.ValidateTags(tags, pkg.ValidateOptions{}))
+}
+
+func TestImportFOFA(t *testing.T) {
+	data := `[{"name":"Acme","rule":"title==\"Acme\" && (header=\"acme-sid\" || icon_hash=\"-99\") && port=\"8443\""}]`
+
+	tags, warnings, err := ImportRules("fofa", []byte(data), nil)
+	assert.NoError(t, err)
+	// 两个合取项拆分为同一规则的两个匹配块, 不支持的port条件被忽略
+	assert.Len(t, tags, 1)
+	assert.Equal(t, "Acme", tags[0].Info.Name)
+	assert.Len(t, tags[0].HTTP, 2)
+	assert.Equal(t, "and", tags[0].HTTP[0].Mode)
+	assert.Equal(t, []pkg.Matchers{
+		{Type: "word", Part: "title", Words: []string{"^Acme$"}, CaseInsensitive: true},
+		{Type: "favicon", Hash: []string{"-99"}},
+	}, tags[0].HTTP[1].Matchers)
+	assert.Len(t, warnings, 2)
+}
+
+func TestImportWappalyzer(t *testing.T) {
+	data := `{"technologies":{
+		"Nginx":{"cats":[22],"headers":{"Server":"nginx(?:/([\\d.]+))?\\;version:\\1"},"implies":"Lua\\;confidence:50"},
+		"WordPress":{"meta":{"generator":"^WordPress ?([\\d.]+)?\\;version:\\1"},"js":{"wp":""},"scriptSrc":"/wp-(?:content|includes)/"},
+		"JsOnly":{"js":{"x":""}}}}`
+
+	tags, warnings, err := ImportRules("wappalyzer", []byte(data), nil)
+	assert.NoError(t, err)
+	assert.Len(t, tags, 2)
+	assert.Equal(t, []string{`(?m)^server: ?[^"'>\n]*nginx(?:/([\d.]+))?`}, tags[0].HTTP[0].Matchers[0].Words)
+	assert.Equal(t, []string{"Lua"}, tags[0].Info.Implies)
+	assert.Len(t, tags[1].HTTP[0].Matchers[0].Words, 2)
+	assert.Len(t, warnings, 3)
+	assert.Empty(t, pkg.ValidateTags(tags, pkg.ValidateOptions{}))
+}
@@ -0,0 +1,207 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/enenisme/definger/pkg"
+)
+
+// wappalyzerIgnored 不参与匹配的描述性字段
+var wappalyzerIgnored = map[string]struct{}{
+	"cats": {}, "website": {}, "icon": {}, "description": {}, "pricing": {},
+	"saas": {}, "oss": {}, "cpe": {}, "implies": {}, "excludes": {}, "requires": {},
+}
+
+// stringOrList 兼容Wappalyzer中既可以是字符串也可以是列表的字段
+type stringOrList []string
+
+// UnmarshalJSON 解析字符串或字符串列表
+func (s *stringOrList) UnmarshalJSON(data []byte) error {
+	var list []string
+	if err := json.Unmarshal(data, &list); err == nil {
+		*s = list
+		return nil
+	}
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	*s = []string{value}
+	return nil
+}
+
+// wappalyzer 转换Wappalyzer的technologies JSON, 每个技术生成一条规则
+// 支持 {"technologies": {...}} 与按首字母拆分的 {"名称": {...}} 两种文件
+// 参数:
+//   - data: technologies JSON内容
+//
+// 返回值:
+//   - []pkg.Tag: 指纹规则
+//   - []pkg.Issue: 转换警告
+//   - error: 错误信息
+func (im *ruleImporter) wappalyzer(data []byte) ([]pkg.Tag, []pkg.Issue, error) {
+	var technologies map[string]map[string]json.RawMessage
+	var wrapped struct {
+		Technologies map[string]map[string]json.RawMessage `json:"technologies"`
+	}
+	if err := json.Unmarshal(data, &wrapped); err == nil && wrapped.Technologies != nil {
+		technologies = wrapped.Technologies
+	} else if err := json.Unmarshal(data, &technologies); err != nil {
+		return nil, nil, fmt.Errorf("解析Wappalyzer指纹文件失败: %v", err)
+	}
+
+	var tags []pkg.Tag
+	for _, name := range sortedKeys(technologies) {
+		if tag, ok := im.wappalyzerTech(name, technologies[name]); ok {
+			tags = append(tags, tag)
+		}
+	}
+	return tags, im.warnings, nil
+}
+
+// wappalyzerTech 转换单个技术, 各类特征之间为or关系
+// 参数:
+//   - name: 技术名称
+//   - fields: 技术定义
+//
+// 返回值:
+//   - pkg.Tag: 指纹规则
+//   - bool: 是否存在可转换的特征
+func (im *ruleImporter) wappalyzerTech(name string, fields map[string]json.RawMessage) (pkg.Tag, bool) {
+	path := fmt.Sprintf("$[%q]", name)
+	var header, body, issuer []string
+	var unsupported []string
+
+	for _, key := range sortedKeys(fields) {
+		raw := fields[key]
+		var err error
+		switch key {
+		case "headers":
+			var headers map[string]string
+			if err = json.Unmarshal(raw, &headers); err == nil {
+				for _, field := range sortedKeys(headers) {
+					header = im.appendPattern(header, name, path+".headers", headerPattern(field, headers[field]))
+				}
+			}
+		case "cookies":
+			var cookies map[string]string
+			if err = json.Unmarshal(raw, &cookies); err == nil {
+				for _, cookie := range sortedKeys(cookies) {
+					header = im.appendPattern(header, name, path+".cookies", `(?m)^set-cookie:.*`+regexp.QuoteMeta(strings.ToLower(cookie))+`=`)
+				}
+			}
+		case "html", "scripts":
+			var patterns stringOrList
+			if err = json.Unmarshal(raw, &patterns); err == nil {
+				for _, pattern := range patterns {
+					body = im.appendPattern(body, name, path+"."+key, wappalyzerPattern(pattern))
+				}
+			}
+		case "scriptSrc":
+			var patterns stringOrList
+			if err = json.Unmarshal(raw, &patterns); err == nil {
+				for _, pattern := range patterns {
+					body = im.appendPattern(body, name, path+".scriptSrc", attrPattern(`<script[^>]*src=["']?`, wappalyzerPattern(pattern)))
+				}
+			}
+		case "meta":
+			var metas map[string]stringOrList
+			if err = json.Unmarshal(raw, &metas); err == nil {
+				for _, meta := range sortedKeys(metas) {
+					prefix := `<meta[^>]*(?:name|property)=["']?` + regexp.QuoteMeta(meta) + `["']?[^>]*content=["']?`
+					for _, pattern := range metas[meta] {
+						body = im.appendPattern(body, name, path+".meta", attrPattern(prefix, wappalyzerPattern(pattern)))
+					}
+				}
+			}
+		case "certIssuer":
+			var value string
+			if err = json.Unmarshal(raw, &value); err == nil {
+				issuer = im.appendPattern(issuer, name, path+".certIssuer", regexp.QuoteMeta(value))
+			}
+		default:
+			if _, ok := wappalyzerIgnored[key]; !ok {
+				unsupported = append(unsupported, key)
+			}
+		}
+		if err != nil {
+			im.warn("", path+"."+key, "%s: 解析%s失败: %v", name, key, err)
+		}
+	}
+	if len(unsupported) > 0 {
+		im.warn("", path, "%s: 不支持的特征 %s, 已忽略", name, strings.Join(unsupported, ", "))
+	}
+
+	var matchers []pkg.Matchers
+	if len(header) > 0 {
+		matchers = append(matchers, wordMatcher("header", "", header...))
+	}
+	if len(body) > 0 {
+		matchers = append(matchers, wordMatcher("body", "", body...))
+	}
+	if len(issuer) > 0 {
+		matchers = append(matchers, wordMatcher("cert.issuer", "", issuer...))
+	}
+	if len(matchers) == 0 {
+		im.warn("", path, "%s: 没有可转换的特征, 已跳过", name)
+		return pkg.Tag{}, false
+	}
+
+	tag := im.newTag(name)
+	tag.HTTP = []pkg.HTTP{{Method: "GET", Path: []string{"/"}, Mode: "or", Matchers: matchers}}
+	tag.Info.Implies = wappalyzerNames(fields["implies"])
+	tag.Info.Excludes = wappalyzerNames(fields["excludes"])
+	tag.Info.Requires = wappalyzerNames(fields["requires"])
+	return tag, true
+}
+
+// wappalyzerNames 解析implies/excludes/requires中的技术名称, 去除 \; 之后的附加信息
+func wappalyzerNames(raw json.RawMessage) []string {
+	var names stringOrList
+	if raw == nil || json.Unmarshal(raw, &names) != nil {
+		return nil
+	}
+	result := make([]string, 0, len(names))
+	for _, name := range names {
+		result = append(result, wappalyzerPattern(name))
+	}
+	return result
+}
+
+// appendPattern 追加可编译的正则, 无法编译时(例如使用了前瞻断言)记录警告
+func (im *ruleImporter) appendPattern(words []string, name, path, pattern string) []string {
+	if !validPattern(pattern) {
+		im.warn("", path, "%s: 正则 %q 无法编译, 已忽略", name, pattern)
+		return words
+	}
+	return append(words, pattern)
+}
+
+// wappalyzerPattern 去除Wappalyzer正则中 \; 之后的version/confidence等附加信息
+func wappalyzerPattern(pattern string) string {
+	if i := strings.Index(pattern, `\;`); i >= 0 {
+		return pattern[:i]
+	}
+	return pattern
+}
+
+// headerPattern 生成匹配指定响应头的正则, 响应头部位为小写的 name: value 行
+func headerPattern(field, pattern string) string {
+	prefix := `(?m)^` + regexp.QuoteMeta(strings.ToLower(field)) + `:`
+	return attrPattern(prefix+` ?`, wappalyzerPattern(pattern))
+}
+
+// attrPattern 拼接属性前缀与值正则, 值正则以^开头时紧接前缀, 否则允许值中任意位置出现
+func attrPattern(prefix, pattern string) string {
+	switch {
+	case pattern == "":
+		return prefix
+	case strings.HasPrefix(pattern, "^"):
+		return prefix + pattern[1:]
+	default:
+		return prefix + `[^"'>\n]*` + pattern
+	}
+}
@@ -2,7 +2,9 @@ package utils
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
+	"regexp"
 	"strings"
 
 	"github.com/enenisme/definger/logger"
@@ -34,40 +36,6 @@ type Rule struct {
 	InIcoMd5 string `json:"inIcoMd5"` // favicon.ico的MD5匹配规则
 }
 
-// NewInfo 创建新的info字段
-func NewInfo(name string) map[string]interface{} {
-	return map[string]interface{}{
-		"name":     name,
-		"author":   "",
-		"tags":     "",
-		"severity": "info",
-		"metadata": map[string]interface{}{
-			"product":  "",
-			"vendor":   "",
-			"verified": true,
-		},
-	}
-}
-
-// NewHttpMatcher 创建新的HTTP匹配器
-// 参数:
-//   - method: HTTP请求方法
-//   - path: HTTP请求路径
-//
-// 返回值:
-//   - map[string]interface{}: 新的HTTP匹配器
-func NewHttpMatcher(method, path, mode string) map[string]interface{} {
-	if mode == "" {
-		mode = "or"
-	}
-	return map[string]interface{}{
-		"method":   method,
-		"path":     []string{path},
-		"mode":     mode,
-		"matchers": []interface{}{},
-	}
-}
-
 // readJsonFile 从文件中读取JSON数据并解析为JsonData结构
 // 参数:
 //   - filepath: 文件路径
@@ -88,88 +56,126 @@ func readJsonFile(filepath string) ([]JsonData, error) {
 	return jsonData, nil
 }
 
-// json2Json 将JsonData结构转换为新的JSON格式
+// json2Json 将JsonData结构转换为指纹规则
+// 匹配表达式含有无法在一个匹配块中表示的或时拆分为同一规则的多个匹配块
 // 参数:
 //   - jsonData: JsonData结构数组
+//   - mapping: 元数据映射, 依次按规则名称与规则ID查找, 可为空
 //
 // 返回值:
-//   - map[string]interface{}: 新的JSON格式
-func json2Json(jsonData []JsonData) []map[string]interface{} {
-	var result []map[string]interface{}
-
-	for _, data := range jsonData {
-		newJson := make(map[string]interface{})
-		newJson["id"] = data.Name
-		newJson["info"] = NewInfo(data.Name)
-
-		httpMatcher := NewHttpMatcher(data.Http.ReqMethod, data.Http.ReqPath, data.Mode)
-		matchers := httpMatcher["matchers"].([]interface{})
-
-		// 处理匹配规则
-		if data.Rule.InHeader != "" {
-			headerArray, mode := parseMatchRule(data.Rule.InHeader)
-			matchers = append(matchers, createMatcher(headerArray, "header", mode))
+//   - []pkg.Tag: 指纹规则
+//   - []pkg.Issue: 无法准确转换的规则
+func json2Json(jsonData []JsonData, mapping MetaMapping) ([]pkg.Tag, []pkg.Issue) {
+	im := newRuleImporter("legacy")
+	var tags []pkg.Tag
+
+	for i, data := range jsonData {
+		path := fmt.Sprintf("$[%d].rule", i)
+		expr, ok := im.legacyExpr(data, path)
+		if !ok {
+			continue
 		}
-		if data.Rule.InBody != "" {
-			bodyArray, mode := parseMatchRule(data.Rule.InBody)
-			matchers = append(matchers, createMatcher(bodyArray, "body", mode))
+
+		https := im.exprHTTP(data.Name, expr, path, legacyMatcher)
+		if len(https) == 0 {
+			continue
 		}
-		if data.Rule.InIcoMd5 != "" {
-			matchers = append(matchers, createMatcher([]string{data.Rule.InIcoMd5}, "favicon", ""))
+		for j := range https {
+			https[j].Method, https[j].Path = data.Http.ReqMethod, []string{data.Http.ReqPath}
+			https[j].Headers, https[j].Body = legacyHeaders(data.Http.ReqHeader), data.Http.ReqBody
 		}
-
-		httpMatcher["matchers"] = matchers
-		newJson["http"] = []interface{}{httpMatcher}
-
-		result = append(result, newJson)
+		tag := pkg.Tag{ID: data.Name, Info: legacyInfo(data.Name), HTTP: https}
+		mapping.apply(&tag, data.Name, data.ID)
+		tags = append(tags, tag)
 	}
-	return result
+	return tags, im.warnings
 }
 
-// parseMatchRule 解析匹配规则字符串,返回规则数组和匹配模式
+// legacyExpr 解析旧版规则的各匹配表达式, 按规则模式组合
 // 参数:
-//   - content: 匹配规则字符串
+//   - data: 旧版规则
+//   - path: 匹配条件在源文件中的位置
 //
 // 返回值:
-//   - []string: 规则数组
-//   - string: 匹配模式
-func parseMatchRule(content string) ([]string, string) {
-	switch {
-	case strings.Contains(content, "|"):
-		return strings.Split(strings.ReplaceAll(strings.ReplaceAll(content, "(", ""), ")", ""), "|"), "or"
-	case strings.Contains(content, "&&"):
-		return strings.Split(strings.ReplaceAll(strings.ReplaceAll(content, "(", ""), ")", ""), "&&"), "and"
-	default:
-		return []string{content}, ""
+//   - *Expr: 组合后的表达式
+//   - bool: 是否存在可用的表达式, 解析失败时记录警告并跳过整条规则
+func (im *ruleImporter) legacyExpr(data JsonData, path string) (*Expr, bool) {
+	fields := []struct {
+		key, field, content string
+	}{
+		{"inHeader", "header", data.Rule.InHeader},
+		{"inBody", "body", data.Rule.InBody},
+		{"inIcoMd5", pkg.FaviconPart, data.Rule.InIcoMd5},
+	}
+
+	var args []*Expr
+	for _, f := range fields {
+		if strings.TrimSpace(f.content) == "" {
+			continue
+		}
+		expr, err := ParseLegacy(f.content, f.field)
+		if err != nil {
+			im.warn(data.Name, path+"."+f.key, "%s: 无法解析匹配表达式 %q: %v, 已跳过", data.Name, f.content, err)
+			return nil, false
+		}
+		args = append(args, expr)
+	}
+
+	switch len(args) {
+	case 0:
+		im.warn(data.Name, path, "%s: 没有匹配条件, 已跳过", data.Name)
+		return nil, false
+	case 1:
+		return args[0], true
 	}
+	if data.Mode == "and" {
+		return &Expr{Op: ExprAnd, Args: args}, true
+	}
+	return &Expr{Op: ExprOr, Args: args}, true
 }
 
-// createMatcher 根据给定的值、类型和模式创建匹配器
+// legacyMatcher 将旧版规则的条件转换为匹配器, 旧版关键字为字面量
 // 参数:
-//   - values: 规则数组
-//   - matchType: 匹配类型
-//   - mode: 匹配模式
+//   - cond: 条件
 //
 // 返回值:
-//   - map[string]interface{}: 匹配器
-func createMatcher(values []string, matchType, mode string) map[string]interface{} {
-	if matchType == "favicon" {
-		return map[string]interface{}{
-			"type": "favicon",
-			"hash": []string{values[0]},
-		}
+//   - pkg.Matchers: 匹配器
+//   - error: 错误信息
+func legacyMatcher(cond Cond) (pkg.Matchers, error) {
+	if cond.Field == pkg.FaviconPart {
+		return pkg.Matchers{Type: "favicon", Hash: []string{cond.Value}}, nil
 	}
+	return wordMatcher(cond.Field, "", regexp.QuoteMeta(cond.Value)), nil
+}
 
-	if values[0] == "()" {
+// legacyHeaders 复制旧版规则的请求头, 各规则拆分出的匹配块不共享同一映射
+// 参数:
+//   - header: 旧版规则的请求头
+//
+// 返回值:
+//   - map[string]string: 请求头, 没有请求头时为nil
+func legacyHeaders(header map[string]string) map[string]string {
+	if len(header) == 0 {
 		return nil
 	}
+	headers := make(map[string]string, len(header))
+	for name, value := range header {
+		headers[name] = value
+	}
+	return headers
+}
 
-	return map[string]interface{}{
-		"type":             "word",
-		"words":            values,
-		"part":             matchType,
-		"condition":        mode,
-		"case-insensitive": true,
+// legacyInfo 创建旧版规则的info字段
+// 参数:
+//   - name: 指纹名称
+//
+// 返回值:
+//   - pkg.Infos: info字段
+func legacyInfo(name string) pkg.Infos {
+	return pkg.Infos{
+		Name:     name,
+		Severity: "info",
+		Metadata: pkg.Metadatas{Verified: true},
 	}
 }
 
@@ -188,11 +194,12 @@ func writeToFile(filepath string, data []byte) error {
 // 参数:
 //   - filepath: 源JSON文件路径
 //   - newJsonFile: 目标JSON文件路径
+//   - mappingFile: 元数据映射文件路径, 为空时不填充元数据
 //
 // 返回值:
 //   - []byte: 转换后的JSON字节数组
 //   - error: 可能的错误
-func Json2Json(filepath, newJsonFile string, logger *logger.Logger) []byte {
+func Json2Json(filepath, newJsonFile, mappingFile string, logger *logger.Logger) []byte {
 	logger.Infof("开始转换JSON文件")
 	jsonData, err := readJsonFile(filepath)
 	if err != nil {
@@ -200,25 +207,20 @@ func Json2Json(filepath, newJsonFile string, logger *logger.Logger) []byte {
 		return nil
 	}
 
-	// 使用MarshalIndent进行格式化输出
-	data, err := json.Marshal(json2Json(jsonData))
+	mapping, err := LoadMetaMapping(mappingFile)
 	if err != nil {
-		logger.Errorf("格式化JSON失败: %s", err)
+		logger.Errorf("%s", err)
 		return nil
 	}
 
-	// 下面的代码是为了将 map 的字段顺序写入文件
-	// 转换为Tag结构
-	var tags []pkg.Tag
-	if err := json.Unmarshal(data, &tags); err != nil {
-		logger.Errorf("验证JSON结构失败: %s", err)
-		return nil
+	tags, warnings := json2Json(jsonData, mapping)
+	for _, warning := range warnings {
+		logger.Warnf("%s: %s", filepath, warning)
 	}
 
-	// 顺序输出
 	tagsData, err := json.Marshal(tags)
 	if err != nil {
-		logger.Errorf("验证JSON结构失败: %s", err)
+		logger.Errorf("格式化JSON失败: %s", err)
 		return nil
 	}
 
@@ -227,7 +229,7 @@ func Json2Json(filepath, newJsonFile string, logger *logger.Logger) []byte {
 		return nil
 	}
 
-	logger.Infof("Json文件转换成功!")
+	logger.Infof("Json文件转换成功! 规则 %d 条, 未能准确转换 %d 处", len(tags), len(warnings))
 
-	return data
+	return tagsData
 }
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/enenisme/definger/logger"
+	"github.com/enenisme/definger/pkg"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -11,6 +12,81 @@ func Test_json2json(t *testing.T) {
 	oldJsonPath := "../test/rule.json"
 	newJsonPath := "../test/new.json"
 	logger := logger.NewLogger(logger.LogLevelDebug)
-	jsonData := Json2Json(oldJsonPath, newJsonPath, logger)
+	jsonData := Json2Json(oldJsonPath, newJsonPath, "", logger)
 	assert.NotNil(t, jsonData)
 }
+
+func TestJson2JsonExpressions(t *testing.T) {
+	rule := func(name, mode, body, header string) JsonData {
+		return JsonData{Name: name, Mode: mode, Http: Http{ReqMethod: "GET", ReqPath: "/"}, Rule: Rule{InBody: body, InHeader: header}}
+	}
+	tags, warnings := json2Json([]JsonData{
+		rule("Nested", "and", "(a|b)&&!c", "x-app"),
+		rule("Split", "or", "(a&&b)|(c&&d)", ""),
+		rule("Escaped", "or", `x\|y | (z)`, ""),
+		rule("Broken", "or", "a&&", ""),
+	}, nil)
+
+	ids := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		ids = append(ids, tag.ID)
+	}
+	assert.Equal(t, []string{"Nested", "Split", "Escaped"}, ids)
+
+	nested := tags[0].HTTP[0]
+	assert.Equal(t, "and", nested.Mode)
+	assert.Equal(t, []pkg.Matchers{
+		{Type: "word", Part: "header", Words: []string{"x-app"}, CaseInsensitive: true},
+		{Type: "word", Part: "body", Words: []string{"a", "b"}, CaseInsensitive: true},
+		{Type: "word", Part: "body", Words: []string{"c"}, CaseInsensitive: true, Negative: true},
+	}, nested.Matchers)
+	// 析取范式的各项作为同一规则的and块, 任一块命中即命中
+	split := tags[1].HTTP
+	assert.Len(t, split, 2)
+	for _, http := range split {
+		assert.Equal(t, "and", http.Mode)
+		assert.Len(t, http.Matchers, 2)
+	}
+	body := func(text string) pkg.ContentFunc {
+		return func(part string, hops bool) (string, bool) {
+			return text, part == "body"
+		}
+	}
+	rules := pkg.NewRuleSet(tags[1:2])
+	assert.Equal(t, []string{"Split"}, rules.Match(body("c d"), nil))
+	assert.Empty(t, rules.Match(body("a c"), nil))
+	assert.Equal(t, []string{`x\|y`, "z"}, tags[2].HTTP[0].Matchers[0].Words)
+
+	assert.Len(t, warnings, 1)
+	assert.Equal(t, "$[3].rule.inBody", warnings[0].Path)
+}
+
+func TestJson2JsonRequestAndMetadata(t *testing.T) {
+	data := JsonData{
+		ID:   "101",
+		Name: "Portal",
+		Http: Http{
+			ReqMethod: "POST",
+			ReqPath:   "/api/login",
+			ReqHeader: map[string]string{"Content-Type": "application/json"},
+			ReqBody:   `{"user":"admin"}`,
+		},
+		Rule: Rule{InBody: "(a&&b)|(c&&d)"},
+	}
+	mapping := MetaMapping{"101": {Vendor: "acme", Product: "portal", Tags: "login, panel"}}
+	tags, warnings := json2Json([]JsonData{data}, mapping)
+	assert.Empty(t, warnings)
+	assert.Len(t, tags, 1)
+
+	tag := tags[0]
+	assert.Equal(t, "Portal", tag.ID)
+	assert.Equal(t, "acme", tag.Info.Metadata.Vendor)
+	assert.Equal(t, "portal", tag.Info.Metadata.Product)
+	assert.Equal(t, "login,panel", tag.Info.Tags)
+	assert.Len(t, tag.HTTP, 2)
+	for _, http := range tag.HTTP {
+		assert.Equal(t, "POST", http.Method)
+		assert.Equal(t, map[string]string{"Content-Type": "application/json"}, http.Headers)
+		assert.Equal(t, `{"user":"admin"}`, http.Body)
+	}
+}
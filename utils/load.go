@@ -1,44 +1,266 @@
 package utils
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
 	"runtime"
 	"strings"
 
+	"gopkg.in/yaml.v3"
+
 	"github.com/enenisme/definger/pkg"
+	"github.com/enenisme/definger/rules"
 )
 
+// BuiltinSource 内置规则包中规则的来源文件
+const BuiltinSource = "builtin:" + rules.File
+
+// ruleExts 从目录加载指纹规则时识别的文件扩展名
+var ruleExts = map[string]struct{}{
+	".json": {},
+	".yaml": {},
+	".yml":  {},
+}
+
 // LoadConfig 一次性加载所有配置
 // 参数:
-//   - filePath: 配置文件路径
+//   - filter: 规则筛选条件, 零值时加载全部规则
+//   - paths: 指纹规则文件或目录路径, 目录递归加载其中的JSON/YAML文件, 为空时使用内置规则包
 //
 // 返回:
 //   - *Config: 配置结构
 //   - error: 错误信息
-func LoadConfig(filePath string) (*pkg.Config, error) {
+func LoadConfig(filter pkg.RuleFilter, paths ...string) (*pkg.Config, error) {
 	var config pkg.Config
 
 	// 加载指纹配置
-	tagsData, err := os.ReadFile(filePath)
+	tags, warnings, err := LoadTags(paths...)
 	if err != nil {
 		return nil, err
 	}
-
-	var tags []pkg.Tag
-	if err := json.Unmarshal(tagsData, &tags); err != nil {
+	if err := checkDuplicateIDs(tags); err != nil {
 		return nil, err
 	}
 
+	// 按筛选条件选择规则子集, 只编译选中的规则
+	if !filter.Empty() {
+		if tags = filter.Apply(tags); len(tags) == 0 {
+			return nil, fmt.Errorf("没有符合筛选条件的指纹规则")
+		}
+	}
+
 	config = pkg.Config{
-		Tags:   &pkg.Tags{Tags: tags},
-		Probes: ProbesContent2ProbesStruct(ProbesContent),
+		Tags:     &pkg.Tags{Tags: tags},
+		Warnings: warnings,
+		Rules:    pkg.NewRuleSet(tags),
+		Probes:   ProbesContent2ProbesStruct(ProbesContent),
 	}
 
 	return &config, nil
 }
 
+// LoadTags 加载指纹规则文件, 合并为一个规则列表并记录每条规则的来源文件
+// 参数:
+//   - paths: 指纹规则文件或目录路径, 为空时加载内置规则包
+//
+// 返回:
+//   - []pkg.Tag: 指纹规则, 按文件顺序排列
+//   - []pkg.Issue: 转换nuclei模板时产生的警告
+//   - error: 错误信息, 包含每个加载失败的文件
+func LoadTags(paths ...string) ([]pkg.Tag, []pkg.Issue, error) {
+	if len(paths) == 0 {
+		tags, err := LoadDefaultTags()
+		return tags, nil, err
+	}
+
+	files, err := RuleFiles(paths...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var tags []pkg.Tag
+	var warnings []pkg.Issue
+	var errs []error
+	for _, file := range files {
+		fileTags, fileWarnings, err := loadTagFile(file)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", file, err))
+			continue
+		}
+		for i := range fileTags {
+			fileTags[i].Source = file
+		}
+		for i := range fileWarnings {
+			fileWarnings[i].File = file
+		}
+		tags = append(tags, fileTags...)
+		warnings = append(warnings, fileWarnings...)
+	}
+	if len(errs) > 0 {
+		return nil, nil, errors.Join(errs...)
+	}
+	return tags, warnings, nil
+}
+
+// LoadDefaultTags 加载内置规则包
+// 返回:
+//   - []pkg.Tag: 指纹规则, 来源文件为BuiltinSource
+//   - error: 错误信息
+func LoadDefaultTags() ([]pkg.Tag, error) {
+	tags, err := parseTags(rules.Default)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", BuiltinSource, err)
+	}
+	for i := range tags {
+		tags[i].Source = BuiltinSource
+	}
+	return tags, nil
+}
+
+// RuleFiles 展开指纹规则路径, 目录按文件名顺序递归查找JSON/YAML文件
+// 参数:
+//   - paths: 指纹规则文件或目录路径
+//
+// 返回:
+//   - []string: 指纹规则文件路径
+//   - error: 错误信息
+func RuleFiles(paths ...string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		// 显式指定的文件不限制扩展名
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		err = filepath.WalkDir(path, func(file string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			if _, ok := ruleExts[strings.ToLower(filepath.Ext(file))]; ok {
+				files = append(files, file)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("未找到指纹规则文件: %s", strings.Join(paths, ", "))
+	}
+	return files, nil
+}
+
+// loadTagFile 加载单个指纹规则文件, 文件内容可以是规则数组、单条规则或nuclei模板
+// 参数:
+//   - filePath: 指纹规则文件路径
+//
+// 返回:
+//   - []pkg.Tag: 指纹规则
+//   - []pkg.Issue: 转换nuclei模板时产生的警告
+//   - error: 错误信息
+func loadTagFile(filePath string) ([]pkg.Tag, []pkg.Issue, error) {
+	tagsData, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// YAML先转换为JSON, 与JSON规则共用同一套字段定义
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".yaml", ".yml":
+		var content interface{}
+		if err := yaml.Unmarshal(tagsData, &content); err != nil {
+			return nil, nil, fmt.Errorf("解析指纹规则文件失败: %v", err)
+		}
+		if isNucleiTemplate(content) {
+			return parseNucleiTemplate(tagsData)
+		}
+		if tagsData, err = json.Marshal(content); err != nil {
+			return nil, nil, fmt.Errorf("解析指纹规则文件失败: %v", err)
+		}
+	}
+
+	tags, err := parseTags(tagsData)
+	return tags, nil, err
+}
+
+// parseTags 解析JSON格式的规则数组或单条规则
+// 参数:
+//   - data: JSON内容
+//
+// 返回:
+//   - []pkg.Tag: 指纹规则
+//   - error: 错误信息
+func parseTags(data []byte) ([]pkg.Tag, error) {
+	var tags []pkg.Tag
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		var tag pkg.Tag
+		if err := json.Unmarshal(trimmed, &tag); err != nil {
+			return nil, fmt.Errorf("解析指纹规则文件失败: %v", err)
+		}
+		return append(tags, tag), nil
+	}
+	if err := json.Unmarshal(data, &tags); err != nil {
+		return nil, fmt.Errorf("解析指纹规则文件失败: %v", err)
+	}
+	return tags, nil
+}
+
+// convertSamples 将YAML中的样例字段转换为样例结构
+// 参数:
+//   - content: YAML解析结果
+//
+// 返回:
+//   - *pkg.Samples: 样例响应
+//   - error: 错误信息
+func convertSamples(content interface{}) (*pkg.Samples, error) {
+	data, err := json.Marshal(content)
+	if err != nil {
+		return nil, fmt.Errorf("解析样例失败: %v", err)
+	}
+	var samples pkg.Samples
+	if err := json.Unmarshal(data, &samples); err != nil {
+		return nil, fmt.Errorf("解析样例失败: %v", err)
+	}
+	return &samples, nil
+}
+
+// checkDuplicateIDs 检查指纹规则ID是否重复
+// 参数:
+//   - tags: 指纹规则
+//
+// 返回:
+//   - error: 重复ID的错误信息
+func checkDuplicateIDs(tags []pkg.Tag) error {
+	seen := make(map[string]string)
+	var errs []error
+	for _, tag := range tags {
+		if tag.ID == "" {
+			continue
+		}
+		if source, ok := seen[tag.ID]; ok {
+			errs = append(errs, fmt.Errorf("%s: 规则ID %q 与 %s 重复", tag.Source, tag.ID, source))
+			continue
+		}
+		seen[tag.ID] = tag.Source
+	}
+	return errors.Join(errs...)
+}
+
 func LoadTargetFile(filePath string) ([]string, error) {
 	// 读取文件
 	file, err := os.Open(filePath)
@@ -62,3 +284,27 @@ func LoadTargetFile(filePath string) ([]string, error) {
 	}
 	return urls, nil
 }
+
+// LoadProxyFile 加载代理列表文件, 每行一个代理地址, 忽略空行和#注释
+// 参数:
+//   - filePath: 代理列表文件路径
+//
+// 返回:
+//   - []string: 代理地址列表
+//   - error: 错误信息
+func LoadProxyFile(filePath string) ([]string, error) {
+	lines, err := LoadTargetFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var proxies []string
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		proxies = append(proxies, line)
+	}
+	return proxies, nil
+}
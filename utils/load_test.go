@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/enenisme/definger/pkg"
+)
+
+func TestLoadTagsDirectory(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+		return path
+	}
+	write("acme/portal.json", `[{"id":"acme-portal","info":{"name":"AcmePortal"},"http":[{"matchers":[{"type":"word","part":"body","words":["acme portal"]}]}]}]`)
+	write("globex/box.yaml", "id: globex-box\ninfo:\n  name: GlobexBox\nhttp:\n  - matchers:\n      - type: word\n        part: header\n        words: [\"globex\"]\n")
+	write("README.txt", "not a rule")
+
+	tags, _, err := LoadTags(dir)
+	assert.NoError(t, err)
+	assert.Len(t, tags, 2)
+	assert.Equal(t, "acme-portal", tags[0].ID)
+	assert.Equal(t, filepath.Join(dir, "acme/portal.json"), tags[0].Source)
+	assert.Equal(t, "globex", tags[1].HTTP[0].Matchers[0].Words[0])
+
+	// 重复ID在加载配置时报错
+	dup := write("dup/portal.yml", "- id: acme-portal\n  info:\n    name: Other\n")
+	_, err = LoadConfig(pkg.RuleFilter{}, dir)
+	assert.ErrorContains(t, err, dup)
+
+	// 每个解析失败的文件都会出现在错误中
+	bad := write("bad.json", "[{")
+	_, _, err = LoadTags(dir, bad)
+	assert.ErrorContains(t, err, bad)
+}
+
+func TestLoadConfigFilter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	rule := "- id: %s\n  info:\n    name: %s\n    tags: %s\n    severity: %s\n  http:\n    - matchers:\n        - {type: word, part: body, words: [%s]}\n"
+	content := fmt.Sprintf(rule, "seeyon", "Seeyon", "oa,cms", "high", "seeyon") +
+		fmt.Sprintf(rule, "weaver", "Weaver", "OA", "critical", "weaver") +
+		fmt.Sprintf(rule, "hfish", "HFish", "oa,honeypot", "info", "hfish") +
+		fmt.Sprintf(rule, "nginx", "Nginx", "server", "info", "nginx")
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+
+	ids := func(filter pkg.RuleFilter) []string {
+		config, err := LoadConfig(filter, path)
+		if !assert.NoError(t, err) {
+			return nil
+		}
+		var ids []string
+		for _, tag := range config.Tags.Tags {
+			ids = append(ids, tag.ID)
+		}
+		assert.Equal(t, len(ids), config.Rules.Len())
+		return ids
+	}
+	assert.Len(t, ids(pkg.RuleFilter{}), 4)
+	assert.Equal(t, []string{"seeyon", "weaver"}, ids(pkg.RuleFilter{Tags: []string{"oa"}, ExcludeTags: []string{"honeypot"}}))
+	assert.Equal(t, []string{"weaver"}, ids(pkg.RuleFilter{Tags: []string{"oa"}, Severities: []string{"Critical"}}))
+	assert.Equal(t, []string{"nginx"}, ids(pkg.RuleFilter{IDs: []string{"nginx", "missing"}}))
+
+	_, err := LoadConfig(pkg.RuleFilter{Tags: []string{"iot"}}, path)
+	assert.Error(t, err)
+}
+
+func TestLoadDefaultTags(t *testing.T) {
+	// 未指定路径时加载内置规则包, 内置规则必须通过校验
+	tags, _, err := LoadTags()
+	assert.NoError(t, err)
+	assert.NotEmpty(t, tags)
+	assert.Equal(t, BuiltinSource, tags[0].Source)
+	assert.Empty(t, pkg.ValidateTags(tags, pkg.ValidateOptions{GenericWords: true}))
+
+	config, err := LoadConfig(pkg.RuleFilter{Tags: []string{"oa"}})
+	assert.NoError(t, err)
+	assert.Less(t, config.Rules.Len(), len(tags))
+}
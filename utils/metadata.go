@@ -0,0 +1,117 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/enenisme/definger/pkg"
+)
+
+// RuleMeta 定义元数据映射文件中单个指纹的元数据, 为空的字段不覆盖转换结果
+type RuleMeta struct {
+	Author  string `json:"author" yaml:"author"`
+	Tags    string `json:"tags" yaml:"tags"` // 逗号分隔, 与转换得到的标签合并
+	Vendor  string `json:"vendor" yaml:"vendor"`
+	Product string `json:"product" yaml:"product"`
+}
+
+// MetaMapping 定义转换时使用的元数据映射, 键为指纹名称或旧版规则ID
+type MetaMapping map[string]RuleMeta
+
+// LoadMetaMapping 加载元数据映射文件, 支持JSON与YAML
+// 参数:
+//   - path: 映射文件路径, 为空时返回空映射
+//
+// 返回值:
+//   - MetaMapping: 元数据映射
+//   - error: 错误信息
+func LoadMetaMapping(path string) (MetaMapping, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取元数据映射文件失败: %v", err)
+	}
+
+	var mapping MetaMapping
+	unmarshal := json.Unmarshal
+	if ext := strings.ToLower(filepath.Ext(path)); ext == ".yaml" || ext == ".yml" {
+		unmarshal = yaml.Unmarshal
+	}
+	if err := unmarshal(data, &mapping); err != nil {
+		return nil, fmt.Errorf("解析元数据映射文件 %s 失败: %v", filepath.Base(path), err)
+	}
+	return mapping, nil
+}
+
+// Apply 按指纹名称为规则填充元数据
+// 参数:
+//   - tags: 指纹规则
+//
+// 返回值:
+//   - int: 填充了元数据的规则数
+func (m MetaMapping) Apply(tags []pkg.Tag) int {
+	applied := 0
+	for i := range tags {
+		if m.apply(&tags[i], tags[i].Info.Name) {
+			applied++
+		}
+	}
+	return applied
+}
+
+// apply 使用第一个存在映射的键为规则填充元数据
+// 参数:
+//   - tag: 指纹规则
+//   - keys: 候选键, 依次查找
+//
+// 返回值:
+//   - bool: 是否找到映射
+func (m MetaMapping) apply(tag *pkg.Tag, keys ...string) bool {
+	for _, key := range keys {
+		meta, ok := m[key]
+		if key == "" || !ok {
+			continue
+		}
+		if meta.Author != "" {
+			tag.Info.Author = meta.Author
+		}
+		if meta.Vendor != "" {
+			tag.Info.Metadata.Vendor = meta.Vendor
+		}
+		if meta.Product != "" {
+			tag.Info.Metadata.Product = meta.Product
+		}
+		tag.Info.Tags = mergeTags(tag.Info.Tags, meta.Tags)
+		return true
+	}
+	return false
+}
+
+// mergeTags 合并逗号分隔的标签并去重, 保持原有顺序
+// 参数:
+//   - lists: 逗号分隔的标签列表
+//
+// 返回值:
+//   - string: 合并后的标签
+func mergeTags(lists ...string) string {
+	seen := make(map[string]bool)
+	var merged []string
+	for _, list := range lists {
+		for _, tag := range strings.Split(list, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "" || seen[tag] {
+				continue
+			}
+			seen[tag] = true
+			merged = append(merged, tag)
+		}
+	}
+	return strings.Join(merged, ",")
+}
@@ -0,0 +1,312 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/enenisme/definger/pkg"
+)
+
+// nucleiTemplate 定义nuclei模板中可转换为指纹规则的部分
+type nucleiTemplate struct {
+	ID       string                 `yaml:"id"`
+	Info     nucleiInfo             `yaml:"info"`
+	HTTP     []nucleiRequest        `yaml:"http"`
+	Requests []nucleiRequest        `yaml:"requests"` // 旧版模板使用requests
+	Samples  interface{}            `yaml:"samples"`  // 本项目扩展的样例响应
+	Extra    map[string]interface{} `yaml:",inline"`
+}
+
+// nucleiInfo 定义nuclei模板的info字段
+type nucleiInfo struct {
+	Name     string                 `yaml:"name"`
+	Author   stringList             `yaml:"author"`
+	Tags     stringList             `yaml:"tags"`
+	Severity string                 `yaml:"severity"`
+	Metadata map[string]interface{} `yaml:"metadata"`
+}
+
+// nucleiRequest 定义nuclei模板的HTTP请求
+type nucleiRequest struct {
+	Method            string                 `yaml:"method"`
+	Path              []string               `yaml:"path"`
+	Headers           map[string]string      `yaml:"headers"`
+	Body              string                 `yaml:"body"`
+	Redirects         bool                   `yaml:"redirects"`     // 引擎总是跟随服务端跳转, 无需转换
+	MaxRedirects      int                    `yaml:"max-redirects"` // 同上
+	Raw               []string               `yaml:"raw"`
+	MatchersCondition string                 `yaml:"matchers-condition"`
+	Matchers          []nucleiMatcher        `yaml:"matchers"`
+	Extractors        []interface{}          `yaml:"extractors"`
+	Extra             map[string]interface{} `yaml:",inline"`
+}
+
+// nucleiMatcher 定义nuclei模板的匹配器
+type nucleiMatcher struct {
+	Name            string                 `yaml:"name"`
+	Type            string                 `yaml:"type"`
+	Part            string                 `yaml:"part"`
+	Words           []string               `yaml:"words"`
+	Regex           []string               `yaml:"regex"`
+	Condition       string                 `yaml:"condition"`
+	CaseInsensitive bool                   `yaml:"case-insensitive"`
+	Negative        bool                   `yaml:"negative"`
+	Internal        bool                   `yaml:"internal"`
+	Extra           map[string]interface{} `yaml:",inline"`
+}
+
+// stringList 兼容nuclei中既可以是逗号分隔字符串也可以是列表的字段
+type stringList []string
+
+// UnmarshalYAML 解析字符串或字符串列表
+func (s *stringList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.SequenceNode {
+		var list []string
+		if err := node.Decode(&list); err != nil {
+			return err
+		}
+		*s = list
+		return nil
+	}
+	var value string
+	if err := node.Decode(&value); err != nil {
+		return err
+	}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*s = append(*s, item)
+		}
+	}
+	return nil
+}
+
+// nucleiParts nuclei匹配部位到本项目匹配部位的映射
+var nucleiParts = map[string]string{
+	"":            "body",
+	"body":        "body",
+	"header":      "header",
+	"all_headers": "header",
+}
+
+// nucleiURLVars 可直接去除的URL变量, 请求路径基于目标URL
+var nucleiURLVars = []string{"{{BaseURL}}", "{{RootURL}}"}
+
+// isNucleiTemplate 判断YAML内容是否为nuclei模板
+// 本项目的YAML规则与JSON字段一致, 关键字为正则; nuclei模板的word关键字为字面量, 需区分后转换
+// 参数:
+//   - content: YAML解析结果
+//
+// 返回:
+//   - bool: 是否为nuclei模板
+func isNucleiTemplate(content interface{}) bool {
+	template, ok := content.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	if _, ok := template["requests"]; ok {
+		return true
+	}
+	requests, _ := template["http"].([]interface{})
+	for _, item := range requests {
+		request, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, key := range []string{"matchers-condition", "raw", "extractors"} {
+			if _, ok := request[key]; ok {
+				return true
+			}
+		}
+		paths, _ := request["path"].([]interface{})
+		for _, path := range paths {
+			if s, ok := path.(string); ok && strings.Contains(s, "{{") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parseNucleiTemplate 将nuclei模板转换为指纹规则, 不支持的功能以警告形式返回
+// 参数:
+//   - data: YAML模板内容
+//
+// 返回:
+//   - []pkg.Tag: 指纹规则, 模板中没有可用匹配器时为空
+//   - []pkg.Issue: 转换警告, File字段由调用方填充
+//   - error: 错误信息
+func parseNucleiTemplate(data []byte) ([]pkg.Tag, []pkg.Issue, error) {
+	var template nucleiTemplate
+	if err := yaml.Unmarshal(data, &template); err != nil {
+		return nil, nil, fmt.Errorf("解析nuclei模板失败: %v", err)
+	}
+
+	var warnings []pkg.Issue
+	warn := func(path, format string, args ...interface{}) {
+		warnings = append(warnings, pkg.Issue{ID: template.ID, Path: path, Level: pkg.IssueWarning, Message: fmt.Sprintf(format, args...)})
+	}
+	for _, key := range sortedKeys(template.Extra) {
+		warn("$."+key, "不支持的模板字段 %s, 已忽略", key)
+	}
+
+	tag := pkg.Tag{
+		ID: template.ID,
+		Info: pkg.Infos{
+			Name:     template.Info.Name,
+			Author:   strings.Join(template.Info.Author, ","),
+			Tags:     strings.Join(template.Info.Tags, ","),
+			Severity: template.Info.Severity,
+		},
+	}
+	if template.Info.Metadata != nil {
+		tag.Info.Metadata.Product, _ = template.Info.Metadata["product"].(string)
+		tag.Info.Metadata.Vendor, _ = template.Info.Metadata["vendor"].(string)
+		tag.Info.Metadata.Verified, _ = template.Info.Metadata["verified"].(bool)
+	}
+	if template.Samples != nil {
+		// 样例字段与JSON规则一致, 借助JSON转换
+		samples, err := convertSamples(template.Samples)
+		if err != nil {
+			return nil, nil, err
+		}
+		tag.Samples = samples
+	}
+
+	key, requests := "http", template.HTTP
+	if len(requests) == 0 {
+		key, requests = "requests", template.Requests
+	}
+	for i, request := range requests {
+		block := fmt.Sprintf("$.%s[%d]", key, i)
+		if http, ok := convertNucleiRequest(request, block, warn); ok {
+			tag.HTTP = append(tag.HTTP, http)
+		}
+	}
+	if len(tag.HTTP) == 0 {
+		warn("$."+key, "模板中没有可转换的匹配器, 已跳过")
+		return nil, warnings, nil
+	}
+	return []pkg.Tag{tag}, warnings, nil
+}
+
+// convertNucleiRequest 转换nuclei模板的单个HTTP请求
+// 参数:
+//   - request: nuclei请求
+//   - block: 请求在模板中的路径
+//   - warn: 记录警告的函数
+//
+// 返回:
+//   - pkg.HTTP: 转换后的HTTP匹配块
+//   - bool: 是否存在可用的匹配器
+func convertNucleiRequest(request nucleiRequest, block string, warn func(path, format string, args ...interface{})) (pkg.HTTP, bool) {
+	http := pkg.HTTP{
+		Method:  strings.ToUpper(request.Method),
+		Headers: request.Headers,
+		Body:    request.Body,
+		Mode:    request.MatchersCondition,
+	}
+	if http.Method == "" {
+		http.Method = "GET"
+	}
+
+	for _, key := range sortedKeys(request.Extra) {
+		warn(block+"."+key, "不支持的请求字段 %s, 已忽略", key)
+	}
+	if len(request.Raw) > 0 {
+		warn(block+".raw", "不支持原始请求, 仅保留匹配器")
+	}
+	if len(request.Extractors) > 0 {
+		warn(block+".extractors", "不支持提取器, 已忽略")
+	}
+
+	for j, path := range request.Path {
+		for _, v := range nucleiURLVars {
+			path = strings.TrimPrefix(path, v)
+		}
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+		if strings.Contains(path, "{{") {
+			warn(fmt.Sprintf("%s.path[%d]", block, j), "不支持路径中的模板变量: %s", path)
+		}
+		http.Path = append(http.Path, path)
+	}
+
+	dropped := false
+	for k, m := range request.Matchers {
+		path := fmt.Sprintf("%s.matchers[%d]", block, k)
+		matcher, ok := convertNucleiMatcher(m, path, warn)
+		if !ok {
+			dropped = true
+			continue
+		}
+		http.Matchers = append(http.Matchers, matcher)
+	}
+	if dropped && http.Mode == "and" && len(http.Matchers) > 0 {
+		warn(block+".matchers-condition", "忽略部分匹配器后, and条件的匹配范围变宽, 可能产生误报")
+	}
+	return http, len(http.Matchers) > 0
+}
+
+// convertNucleiMatcher 转换nuclei模板的匹配器
+// 参数:
+//   - m: nuclei匹配器
+//   - path: 匹配器在模板中的路径
+//   - warn: 记录警告的函数
+//
+// 返回:
+//   - pkg.Matchers: 转换后的匹配器
+//   - bool: 是否可以转换
+func convertNucleiMatcher(m nucleiMatcher, path string, warn func(path, format string, args ...interface{})) (pkg.Matchers, bool) {
+	var words []string
+	switch m.Type {
+	case "word":
+		// nuclei的word关键字为字面量, 本项目关键字按正则匹配
+		for _, word := range m.Words {
+			words = append(words, regexp.QuoteMeta(word))
+		}
+	case "regex":
+		words = m.Regex
+	default:
+		warn(path+".type", "不支持的匹配器类型 %s, 已忽略", m.Type)
+		return pkg.Matchers{}, false
+	}
+
+	// 不支持的类型已整体忽略, 只对保留的匹配器提示多余字段
+	for _, key := range sortedKeys(m.Extra) {
+		warn(path+"."+key, "不支持的匹配器字段 %s, 已忽略", key)
+	}
+
+	part, ok := nucleiParts[m.Part]
+	if !ok {
+		warn(path+".part", "不支持的匹配部位 %s, 已忽略该匹配器", m.Part)
+		return pkg.Matchers{}, false
+	}
+	if len(words) == 0 {
+		warn(path, "匹配器没有关键字, 已忽略")
+		return pkg.Matchers{}, false
+	}
+
+	return pkg.Matchers{
+		Type:            "word",
+		Words:           words,
+		Part:            part,
+		Condition:       m.Condition,
+		CaseInsensitive: m.CaseInsensitive,
+		Negative:        m.Negative,
+	}, true
+}
+
+// sortedKeys 按字母顺序获取map的键, 保证转换结果与警告顺序稳定
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const nucleiTemplateYAML = `id: acme-panel
+
+info:
+  name: Acme Panel
+  author: alice,bob
+  severity: info
+  tags: tech,acme
+  metadata:
+    vendor: acme
+    product: panel
+    max-request: 1
+
+http:
+  - method: GET
+    path:
+      - "{{BaseURL}}/login"
+    matchers-condition: and
+    matchers:
+      - type: word
+        part: body
+        words:
+          - "Acme (Panel)"
+      - type: regex
+        part: header
+        regex:
+          - "X-Acme-Version: [0-9.]+"
+      - type: status
+        status:
+          - 200
+    extractors:
+      - type: regex
+        regex:
+          - "v([0-9.]+)"
+`
+
+func TestLoadNucleiTemplate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "acme-panel.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(nucleiTemplateYAML), 0o644))
+
+	tags, warnings, err := LoadTags(path)
+	assert.NoError(t, err)
+	assert.Len(t, tags, 1)
+
+	tag := tags[0]
+	assert.Equal(t, "Acme Panel", tag.Info.Name)
+	assert.Equal(t, "alice,bob", tag.Info.Author)
+	assert.Equal(t, "tech,acme", tag.Info.Tags)
+	assert.Equal(t, "acme", tag.Info.Metadata.Vendor)
+	assert.Equal(t, []string{"/login"}, tag.HTTP[0].Path)
+	assert.Equal(t, "and", tag.HTTP[0].Mode)
+	assert.Len(t, tag.HTTP[0].Matchers, 2)
+	assert.Equal(t, []string{`Acme \(Panel\)`}, tag.HTTP[0].Matchers[0].Words)
+	assert.Equal(t, "header", tag.HTTP[0].Matchers[1].Part)
+
+	paths := make([]string, 0, len(warnings))
+	for _, warning := range warnings {
+		assert.Equal(t, path, warning.File)
+		paths = append(paths, warning.Path)
+	}
+	assert.Equal(t, []string{
+		"$.http[0].extractors",
+		"$.http[0].matchers[2].type",
+		"$.http[0].matchers-condition",
+	}, paths)
+}
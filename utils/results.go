@@ -0,0 +1,209 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// resultSheet SaveExecl写入结果的工作表名称
+const resultSheet = "LJ_Definger"
+
+// SaveJSON 保存指纹数据到JSON文件, 按URL排序便于比较多次扫描的结果
+// 参数:
+//   - fingers: 指纹数据
+//   - filename: 文件名
+//
+// 返回:
+//   - error: 错误信息
+func SaveJSON(fingers map[string]FingerData, filename string) error {
+	urls := make([]string, 0, len(fingers))
+	for url := range fingers {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+
+	results := make([]FingerData, 0, len(urls))
+	for _, url := range urls {
+		results = append(results, fingers[url])
+	}
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化识别结果失败: %v", err)
+	}
+	return os.WriteFile(filename, data, 0644)
+}
+
+// LoadResults 加载历史扫描结果, 支持SaveExecl写入的Excel文件与JSON文件(数组或每行一个对象)
+// 参数:
+//   - paths: 结果文件路径
+//
+// 返回:
+//   - []FingerData: 识别结果, 每个目标一条
+//   - error: 各文件的错误信息
+func LoadResults(paths ...string) ([]FingerData, error) {
+	var results []FingerData
+	var errs []error
+	for _, path := range paths {
+		var (
+			loaded []FingerData
+			err    error
+		)
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".xlsx":
+			loaded, err = loadExcelResults(path)
+		case ".json", ".jsonl":
+			loaded, err = loadJSONResults(path)
+		default:
+			err = fmt.Errorf("不支持的结果文件格式, 可选: .xlsx, .json, .jsonl")
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", path, err))
+			continue
+		}
+		results = append(results, loaded...)
+	}
+	return results, errors.Join(errs...)
+}
+
+// loadExcelResults 读取SaveExecl写入的Excel文件, 按表头定位各列
+// 参数:
+//   - path: 文件路径
+//
+// 返回:
+//   - []FingerData: 识别结果
+//   - error: 错误信息
+func loadExcelResults(path string) ([]FingerData, error) {
+	file, err := excelize.OpenFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开Excel文件失败: %v", err)
+	}
+	defer file.Close()
+
+	sheet := resultSheet
+	if index, err := file.GetSheetIndex(sheet); err != nil || index < 0 {
+		sheet = file.GetSheetName(file.GetActiveSheetIndex())
+	}
+	rows, err := file.GetRows(sheet)
+	if err != nil {
+		return nil, fmt.Errorf("读取工作表 %s 失败: %v", sheet, err)
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	columns := make(map[string]int, len(rows[0]))
+	for i, name := range rows[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["result"]; !ok {
+		return nil, fmt.Errorf("工作表 %s 缺少Result列", sheet)
+	}
+	cell := func(row []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+	split := func(value, sep string) []string {
+		var items []string
+		for _, item := range strings.Split(value, sep) {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		return items
+	}
+
+	results := make([]FingerData, 0, len(rows)-1)
+	for _, row := range rows[1:] {
+		if cell(row, "url") == "" && cell(row, "result") == "" {
+			continue
+		}
+		results = append(results, FingerData{
+			Protocol:   cell(row, "protocol"),
+			Url:        cell(row, "url"),
+			Result:     split(cell(row, "result"), ","),
+			Confidence: parseConfidence(split(cell(row, "confidence"), ",")),
+			Title:      cell(row, "title"),
+			Cert:       cell(row, "cert"),
+			JARM:       cell(row, "jarm"),
+			Redirects:  split(cell(row, "redirects"), " -> "),
+			FinalURL:   cell(row, "finalurl"),
+		})
+	}
+	return results, nil
+}
+
+// parseConfidence 解析formatConfidence生成的"指纹:置信度"列表, 忽略无法解析的项
+// 参数:
+//   - items: "指纹:置信度"列表
+//
+// 返回:
+//   - map[string]float64: 各指纹的置信度, 没有可解析的项时为nil
+func parseConfidence(items []string) map[string]float64 {
+	var confidence map[string]float64
+	for _, item := range items {
+		i := strings.LastIndex(item, ":")
+		if i <= 0 {
+			continue
+		}
+		c, err := strconv.ParseFloat(strings.TrimSpace(item[i+1:]), 64)
+		if err != nil {
+			continue
+		}
+		if confidence == nil {
+			confidence = make(map[string]float64)
+		}
+		confidence[strings.TrimSpace(item[:i])] = c
+	}
+	return confidence
+}
+
+// loadJSONResults 读取JSON结果文件, 支持数组与每行一个对象两种形式
+// 参数:
+//   - path: 文件路径
+//
+// 返回:
+//   - []FingerData: 识别结果
+//   - error: 错误信息
+func loadJSONResults(path string) ([]FingerData, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		var results []FingerData
+		if err := json.Unmarshal(trimmed, &results); err != nil {
+			return nil, fmt.Errorf("解析JSON结果失败: %v", err)
+		}
+		return results, nil
+	}
+
+	var results []FingerData
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		var result FingerData
+		if err := json.Unmarshal(text, &result); err != nil {
+			return nil, fmt.Errorf("第 %d 行: 解析JSON结果失败: %v", line, err)
+		}
+		results = append(results, result)
+	}
+	return results, scanner.Err()
+}
@@ -2,16 +2,23 @@ package utils
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/xuri/excelize/v2"
 )
 
+// FingerData 定义单个目标的识别结果, Excel与JSON结果文件共用
 type FingerData struct {
-	Protocol string
-	Url      string
-	Result   []string
-	Title    string
+	Protocol   string             `json:"protocol"`
+	Url        string             `json:"url"`
+	Result     []string           `json:"result"`
+	Confidence map[string]float64 `json:"confidence,omitempty"` // 各指纹的置信度
+	Title      string             `json:"title,omitempty"`
+	Cert       string             `json:"cert,omitempty"`
+	JARM       string             `json:"jarm,omitempty"`
+	Redirects  []string           `json:"redirects,omitempty"`
+	FinalURL   string             `json:"finalUrl,omitempty"`
 }
 
 // SaveExecl 保存指纹数据到Excel文件
@@ -30,6 +37,11 @@ func SaveExecl(fingers map[string]FingerData, filename string) error {
 	file.SetCellValue(sheet, "B1", "Url")
 	file.SetCellValue(sheet, "C1", "Result")
 	file.SetCellValue(sheet, "D1", "Title") // 修复了标题行的错误,将C1改为D1
+	file.SetCellValue(sheet, "E1", "Cert")
+	file.SetCellValue(sheet, "F1", "JARM")
+	file.SetCellValue(sheet, "G1", "Redirects")
+	file.SetCellValue(sheet, "H1", "FinalURL")
+	file.SetCellValue(sheet, "I1", "Confidence")
 
 	row := 2
 	for _, finger := range fingers {
@@ -39,8 +51,30 @@ func SaveExecl(fingers map[string]FingerData, filename string) error {
 		// 将Result数组转换为字符串后写入
 		file.SetCellValue(sheet, fmt.Sprintf("C%d", row), strings.Join(finger.Result, ","))
 		file.SetCellValue(sheet, fmt.Sprintf("D%d", row), finger.Title)
+		file.SetCellValue(sheet, fmt.Sprintf("E%d", row), finger.Cert)
+		file.SetCellValue(sheet, fmt.Sprintf("F%d", row), finger.JARM)
+		file.SetCellValue(sheet, fmt.Sprintf("G%d", row), strings.Join(finger.Redirects, " -> "))
+		file.SetCellValue(sheet, fmt.Sprintf("H%d", row), finger.FinalURL)
+		file.SetCellValue(sheet, fmt.Sprintf("I%d", row), formatConfidence(finger.Result, finger.Confidence))
 		row++
 	}
 	return file.SaveAs(filename)
 
 }
+
+// formatConfidence 按结果顺序将置信度格式化为"指纹:置信度"并以逗号连接
+// 参数:
+//   - results: 指纹结果
+//   - confidence: 各指纹的置信度
+//
+// 返回:
+//   - string: 格式化后的置信度
+func formatConfidence(results []string, confidence map[string]float64) string {
+	items := make([]string, 0, len(results))
+	for _, result := range results {
+		if c, ok := confidence[result]; ok {
+			items = append(items, fmt.Sprintf("%s:%s", result, strconv.FormatFloat(c, 'f', -1, 64)))
+		}
+	}
+	return strings.Join(items, ",")
+}
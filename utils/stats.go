@@ -0,0 +1,185 @@
+package utils
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/enenisme/definger/pkg"
+)
+
+// StatsOptions 定义规则命中统计的选项
+type StatsOptions struct {
+	MinCooccur   int     // 共现统计要求的最少共同命中目标数
+	CooccurRatio float64 // 视为总是共现的比例: 共同命中目标数/命中较少一方的目标数
+	MaxProducts  int     // 同一目标在同一类别中命中的指纹数达到该值时视为冲突
+}
+
+// RuleHit 定义单个指纹的命中统计
+type RuleHit struct {
+	Name  string  `json:"name"`
+	Hits  int     `json:"hits"`  // 命中的目标数
+	Ratio float64 `json:"ratio"` // 命中目标数/目标总数
+}
+
+// Cooccurrence 定义总是同时命中的两个指纹
+type Cooccurrence struct {
+	A     string  `json:"a"`
+	B     string  `json:"b"`
+	Count int     `json:"count"` // 共同命中的目标数
+	Ratio float64 `json:"ratio"` // 共同命中目标数/命中较少一方的目标数
+}
+
+// Conflict 定义在同一类别中命中过多指纹或命中互斥指纹的目标
+type Conflict struct {
+	URL      string   `json:"url"`
+	Category string   `json:"category"` // 规则标签, 互斥指纹时为excludes, 未提供规则时为空
+	Results  []string `json:"results"`
+}
+
+// RuleStats 定义历史扫描结果的规则命中统计
+type RuleStats struct {
+	Targets       int            `json:"targets"`
+	Rules         []RuleHit      `json:"rules"`               // 按命中数降序
+	Unmatched     []string       `json:"unmatched,omitempty"` // 从未命中的指纹, 需提供规则
+	Unknown       []string       `json:"unknown,omitempty"`   // 结果中存在但规则中不存在的指纹, 需提供规则
+	Cooccurrences []Cooccurrence `json:"cooccurrences,omitempty"`
+	Conflicts     []Conflict     `json:"conflicts,omitempty"`
+}
+
+// RuleStatistics 统计历史扫描结果中各指纹的命中情况
+// 提供规则时以规则标签为类别判断冲突, 检查互斥指纹并列出从未命中的指纹; 否则所有指纹视为同一类别
+// 参数:
+//   - results: 历史扫描结果
+//   - tags: 指纹规则, 可为空
+//   - opts: 统计选项
+//
+// 返回值:
+//   - *RuleStats: 统计结果
+func RuleStatistics(results []FingerData, tags []pkg.Tag, opts StatsOptions) *RuleStats {
+	stats := &RuleStats{Targets: len(results)}
+
+	// 指纹名称到类别的映射, 同名规则的标签合并; 互斥关系双向记录
+	categories := make(map[string][]string)
+	excludes := make(map[[2]string]bool)
+	for _, tag := range tags {
+		categories[tag.Info.Name] = strings.Split(mergeTags(strings.Join(categories[tag.Info.Name], ","), tag.Info.Tags), ",")
+		for _, excluded := range tag.Info.Excludes {
+			excludes[[2]string{tag.Info.Name, excluded}] = true
+			excludes[[2]string{excluded, tag.Info.Name}] = true
+		}
+	}
+
+	hits := make(map[string]int)
+	pairs := make(map[[2]string]int)
+	unknown := make(map[string]bool)
+	for _, result := range results {
+		names := uniqueSorted(result.Result)
+		for i, name := range names {
+			hits[name]++
+			for _, other := range names[i+1:] {
+				pairs[[2]string{name, other}]++
+				if excludes[[2]string{name, other}] {
+					stats.Conflicts = append(stats.Conflicts, Conflict{URL: result.Url, Category: "excludes", Results: []string{name, other}})
+				}
+			}
+			if _, ok := categories[name]; len(tags) > 0 && !ok {
+				unknown[name] = true
+			}
+		}
+		stats.Conflicts = append(stats.Conflicts, conflictsOf(result.Url, names, categories, len(tags) > 0, opts.MaxProducts)...)
+	}
+
+	for name, count := range hits {
+		stats.Rules = append(stats.Rules, RuleHit{Name: name, Hits: count, Ratio: ratio(count, stats.Targets)})
+	}
+	sort.Slice(stats.Rules, func(i, j int) bool {
+		if stats.Rules[i].Hits != stats.Rules[j].Hits {
+			return stats.Rules[i].Hits > stats.Rules[j].Hits
+		}
+		return stats.Rules[i].Name < stats.Rules[j].Name
+	})
+
+	for name := range categories {
+		if hits[name] == 0 {
+			stats.Unmatched = append(stats.Unmatched, name)
+		}
+	}
+	sort.Strings(stats.Unmatched)
+	stats.Unknown = sortedKeys(unknown)
+
+	for pair, count := range pairs {
+		fewer := hits[pair[0]]
+		if hits[pair[1]] < fewer {
+			fewer = hits[pair[1]]
+		}
+		if count < opts.MinCooccur || ratio(count, fewer) < opts.CooccurRatio {
+			continue
+		}
+		stats.Cooccurrences = append(stats.Cooccurrences, Cooccurrence{A: pair[0], B: pair[1], Count: count, Ratio: ratio(count, fewer)})
+	}
+	sort.Slice(stats.Cooccurrences, func(i, j int) bool {
+		a, b := stats.Cooccurrences[i], stats.Cooccurrences[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		if a.A != b.A {
+			return a.A < b.A
+		}
+		return a.B < b.B
+	})
+	return stats
+}
+
+// conflictsOf 找出目标在同一类别中命中指纹数达到上限的类别
+// 参数:
+//   - url: 目标URL
+//   - names: 目标命中的指纹(已去重排序)
+//   - categories: 指纹名称到类别的映射
+//   - byCategory: 是否按类别划分, 否则所有指纹视为同一类别
+//   - max: 指纹数上限, 小于2时不检查
+//
+// 返回值:
+//   - []Conflict: 冲突的类别
+func conflictsOf(url string, names []string, categories map[string][]string, byCategory bool, max int) []Conflict {
+	if max < 2 || len(names) < max {
+		return nil
+	}
+	if !byCategory {
+		return []Conflict{{URL: url, Results: names}}
+	}
+
+	grouped := make(map[string][]string)
+	for _, name := range names {
+		for _, category := range categories[name] {
+			if category != "" {
+				grouped[category] = append(grouped[category], name)
+			}
+		}
+	}
+	var conflicts []Conflict
+	for _, category := range sortedKeys(grouped) {
+		if len(grouped[category]) >= max {
+			conflicts = append(conflicts, Conflict{URL: url, Category: category, Results: grouped[category]})
+		}
+	}
+	return conflicts
+}
+
+// uniqueSorted 去除空值与重复值并排序
+func uniqueSorted(items []string) []string {
+	seen := make(map[string]bool, len(items))
+	for _, item := range items {
+		if item = strings.TrimSpace(item); item != "" {
+			seen[item] = true
+		}
+	}
+	return sortedKeys(seen)
+}
+
+// ratio 计算比例, 分母为0时返回0
+func ratio(count, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(count) / float64(total)
+}
@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/enenisme/definger/pkg"
+)
+
+func TestLoadResults(t *testing.T) {
+	dir := t.TempDir()
+	fingers := map[string]FingerData{
+		"http://a": {Protocol: "TCP/HTTP", Url: "http://a", Result: []string{"Nginx", "Tomcat"}, Confidence: map[string]float64{"Nginx": 0.5, "Tomcat": 1}, Redirects: []string{"http://a/login"}},
+		"http://b": {Protocol: "TCP/HTTP", Url: "http://b"},
+	}
+	assert.NoError(t, SaveExecl(fingers, filepath.Join(dir, "scan.xlsx")))
+	assert.NoError(t, SaveJSON(fingers, filepath.Join(dir, "scan.json")))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "scan.jsonl"), []byte(`{"url":"http://c","result":["Nginx"]}`+"\n"), 0644))
+
+	results, err := LoadResults(filepath.Join(dir, "scan.xlsx"), filepath.Join(dir, "scan.json"), filepath.Join(dir, "scan.jsonl"))
+	assert.NoError(t, err)
+	assert.Len(t, results, 5)
+	for _, result := range results[:4] {
+		if result.Url == "http://a" {
+			assert.Equal(t, fingers["http://a"], result)
+		}
+	}
+
+	_, err = LoadResults(filepath.Join(dir, "scan.csv"))
+	assert.Error(t, err)
+}
+
+func TestRuleStatistics(t *testing.T) {
+	results := []FingerData{
+		{Url: "http://a", Result: []string{"Nginx", "jQuery", "jQuery UI"}},
+		{Url: "http://b", Result: []string{"Nginx", "jQuery", "jQuery UI", "Seeyon", "Weaver", "Tongda"}},
+		{Url: "http://c", Result: []string{"Nginx"}},
+		{Url: "http://d", Result: []string{"Legacy"}},
+	}
+	tags := []pkg.Tag{
+		{Info: pkg.Infos{Name: "Nginx", Tags: "server"}},
+		{Info: pkg.Infos{Name: "jQuery", Tags: "js"}},
+		{Info: pkg.Infos{Name: "jQuery UI", Tags: "js"}},
+		{Info: pkg.Infos{Name: "Seeyon", Tags: "oa"}},
+		{Info: pkg.Infos{Name: "Weaver", Tags: "oa"}},
+		{Info: pkg.Infos{Name: "Tongda", Tags: "oa"}},
+		{Info: pkg.Infos{Name: "Landray", Tags: "oa"}},
+	}
+
+	stats := RuleStatistics(results, tags, StatsOptions{MinCooccur: 2, CooccurRatio: 0.9, MaxProducts: 3})
+	assert.Equal(t, 4, stats.Targets)
+	assert.Equal(t, RuleHit{Name: "Nginx", Hits: 3, Ratio: 0.75}, stats.Rules[0])
+	assert.Equal(t, []string{"Landray"}, stats.Unmatched)
+	assert.Equal(t, []string{"Legacy"}, stats.Unknown)
+	assert.Equal(t, []Cooccurrence{
+		{A: "Nginx", B: "jQuery", Count: 2, Ratio: 1},
+		{A: "Nginx", B: "jQuery UI", Count: 2, Ratio: 1},
+		{A: "jQuery", B: "jQuery UI", Count: 2, Ratio: 1},
+	}, stats.Cooccurrences)
+	assert.Equal(t, []Conflict{{URL: "http://b", Category: "oa", Results: []string{"Seeyon", "Tongda", "Weaver"}}}, stats.Conflicts)
+}